@@ -0,0 +1,180 @@
+package dbop
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+type actorKey struct{}
+
+// Returns a context carrying the actor recorded in the audit trail, e.g. a user name
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Returns the actor of the context set with WithActor() or an empty string
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// Returns the schema of the audit table used by EnableAudit(). Create the table with it, e.g.
+//
+//	var auditTable dbop.DbTable
+//	auditTable.InitSchema(dbop.AuditTableSchema("audit_log"))
+//	err := auditTable.DoCreateTable(&dbcon, true)
+func AuditTableSchema(tableName string) TableSchema {
+	schema := NewTableSchema(tableName,
+		Column{Name: "recid", Type: "BIGINT", FieldAttr: FieldAttr{NotNull: true}, AutoIncrement: true, PrimaryKey: true},
+		Column{Name: "table_name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 128, NotNull: true}},
+		Column{Name: "record_id", Type: "BIGINT"},
+		Column{Name: "operation", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 10, NotNull: true}},
+		Column{Name: "old_values", Type: "TEXT"},
+		Column{Name: "new_values", Type: "TEXT"},
+		Column{Name: "actor", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 255}},
+		Column{Name: "created_at", Type: "DATETIME", FieldAttr: FieldAttr{NotNull: true}},
+	)
+	schema.AddIndex(tableName+"_record_idx", false, "table_name", "record_id")
+
+	return schema
+}
+
+// Records every DoInsert(), DoUpsert(), DoUpdate(), DoUpdateWhere(), DoDelete() and
+// DoDeleteWhere() of the connection in the audit table, see AuditTableSchema(). An entry holds
+// the table, the recid, the operation, the old and new field values as JSON, the actor of the
+// connection context and the time. Entries are written in the transaction of the operation,
+// operations outside of a transaction are run in one. Bulk updates and deletes write an entry
+// for every affected row.
+func (dbc *DbConnection) EnableAudit(auditTableName string) {
+	dbc.auditTable = auditTableName
+}
+
+// Stops recording writes in the audit table
+func (dbc *DbConnection) DisableAudit() {
+	dbc.auditTable = ""
+}
+
+// Returns true if the writes of the table are recorded in the audit table
+func (dbc *DbConnection) audits(t *DbTable) bool {
+	return len(dbc.auditTable) != 0 && t.tableName != dbc.auditTable
+}
+
+// Runs a write of the table, within a transaction if it is audited and no transaction has been started
+func (dbc *DbConnection) auditTx(t *DbTable, fn func(tx *DbConnection) error) error {
+	if !dbc.audits(t) || dbc.InTransaction() {
+		return fn(dbc)
+	}
+
+	return dbc.transaction(fn)
+}
+
+// Writes an entry to the audit table. Nil values are stored as NULL.
+func (dbc *DbConnection) writeAudit(t *DbTable, operation string, recId uint64, oldValues map[string]string, newValues map[string]string) error {
+	var entry DbTable
+
+	err := entry.InitSchema(AuditTableSchema(dbc.auditTable))
+
+	if err != nil {
+		return err
+	}
+
+	entry.SetFieldValue("table_name", t.tableName)
+	entry.SetFieldValue("operation", operation)
+	entry.SetFieldValue("actor", ActorFromContext(dbc.Context()))
+	entry.SetFieldValue("created_at", dbc.Now().Format(timestampLayout))
+
+	if recId != 0 {
+		entry.SetFieldValue("record_id", strconv.FormatUint(recId, 10))
+	} else {
+		entry.SetFieldExpr("record_id", "NULL")
+	}
+
+	for fieldName, values := range map[string]map[string]string{"old_values": oldValues, "new_values": newValues} {
+		if values == nil {
+			entry.SetFieldExpr(fieldName, "NULL")
+			continue
+		}
+
+		valuesJson, err := json.Marshal(values)
+
+		if err != nil {
+			return err
+		}
+
+		entry.SetFieldValue(fieldName, string(valuesJson))
+	}
+
+	stmtStr, args, err := entry.buildInsertStr(dbc, false)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.exec(stmtStr, args)
+
+	return err
+}
+
+// Returns the values of the fields that are set, or of all fields if the record has been
+// selected. Fields set to expressions are recorded with their expression.
+func (t DbTable) auditValues(all bool) map[string]string {
+	values := make(map[string]string)
+
+	for fId, column := range t.columns {
+		if !all && !t.fieldValueSet[fId] {
+			continue
+		}
+
+		values[column.Name] = t.auditValue(fId)
+	}
+
+	return values
+}
+
+func (t DbTable) auditValue(fId int) string {
+	if expr := t.fieldExprs[fId]; expr != nil {
+		if len(expr.operator) != 0 {
+			return t.columns[fId].Name + " " + expr.operator + " " + expr.value
+		}
+		return expr.expr
+	}
+
+	return t.fieldValue[fId]
+}
+
+// Returns the old and new values of the fields written by an update of the selected record
+func (t DbTable) auditChanges(dirtyOnly bool) (map[string]string, map[string]string) {
+	oldValues := make(map[string]string)
+	newValues := make(map[string]string)
+
+	for fId, column := range t.columns {
+		if !t.fieldValueSet[fId] || (dirtyOnly && !t.isFieldDirty(fId)) {
+			continue
+		}
+
+		oldValues[column.Name] = t.fieldOrig[fId]
+		newValues[column.Name] = t.auditValue(fId)
+	}
+
+	return oldValues, newValues
+}
+
+// Selects the rows a bulk update or delete is going to change, so that they can be audited.
+// A soft delete only changes the rows that have not been deleted.
+func (t DbTable) auditRows(dbc *DbConnection, where *Condition, softDelete bool) ([]DbTable, error) {
+	if !dbc.audits(&t) {
+		return nil, nil
+	}
+
+	tbl := t.newTableInstance()
+	tbl.hooks = nil
+	tbl.lockMode = LockNone
+	tbl.deletedScope = withDeleted
+
+	if softDelete {
+		tbl.deletedScope = withoutDeleted
+	}
+
+	return tbl.doSelect(dbc, where)
+}
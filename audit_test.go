@@ -0,0 +1,85 @@
+package dbop
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var users, audit DbTable
+	createTestTable(t, dbc, &users, NewTableSchema("users", Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}}))
+
+	err := audit.InitSchema(AuditTableSchema("audit_log"))
+
+	if err == nil {
+		err = audit.DoCreateTable(dbc, false)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbc.EnableAudit("audit_log")
+	conn := dbc.WithContext(WithActor(context.Background(), "admin"))
+
+	users.SetFieldValue("name", "ann")
+
+	if err = users.DoInsert(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	recId, _ := users.RecId()
+
+	users.SetFieldValue("name", "anna")
+
+	if err = users.DoUpdate(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = users.DoDelete(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := audit.DoSelect(dbc)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		operation string
+		oldName   string
+		newName   string
+	}{{"INSERT", "", "ann"}, {"UPDATE", "ann", "anna"}, {"DELETE", "anna", ""}}
+
+	if len(entries) != len(want) {
+		t.Fatalf("got %d audit entries, want %d", len(entries), len(want))
+	}
+
+	for i, entry := range entries {
+		if entry.GetFieldValue("table_name") != "users" || entry.GetFieldValue("operation") != want[i].operation ||
+			entry.GetFieldValue("actor") != "admin" || entry.GetFieldValue("record_id") != strconv.FormatUint(recId, 10) {
+			t.Errorf("entry %d: got %s %s %s by %s", i, entry.GetFieldValue("table_name"), entry.GetFieldValue("operation"),
+				entry.GetFieldValue("record_id"), entry.GetFieldValue("actor"))
+		}
+
+		for fieldName, name := range map[string]string{"old_values": want[i].oldName, "new_values": want[i].newName} {
+			var values map[string]string
+
+			if len(name) == 0 {
+				if value := entry.GetFieldValue(fieldName); len(value) != 0 {
+					t.Errorf("entry %d: got %s %s", i, fieldName, value)
+				}
+				continue
+			}
+
+			if err = json.Unmarshal([]byte(entry.GetFieldValue(fieldName)), &values); err != nil || values["name"] != name {
+				t.Errorf("entry %d: got %s %s, %v", i, fieldName, entry.GetFieldValue(fieldName), err)
+			}
+		}
+	}
+}
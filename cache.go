@@ -0,0 +1,251 @@
+package dbop
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Stores the scanned rows of selects, see DbConnection.SetCache(). Implementations must be
+// safe for concurrent use.
+type Cache interface {
+	// Returns the rows stored for the key and true, or false if there are none
+	Get(key string) ([][]interface{}, bool)
+
+	// Returns the invalidation generation of the table, changed by every InvalidateTable()
+	Generation(tableName string) uint64
+
+	// Stores the rows of a select from the table under the key. The rows are dropped if the
+	// table has been invalidated since the generation was read before the select.
+	Set(key string, tableName string, generation uint64, rows [][]interface{})
+
+	// Removes all rows stored for selects from the table
+	InvalidateTable(tableName string)
+}
+
+// An in-process Cache keeping a limited number of selects for a limited time. The least
+// recently used select is removed when the cache is full.
+type LRUCache struct {
+	mutex       sync.Mutex
+	size        int
+	ttl         time.Duration
+	entries     map[string]*list.Element
+	order       *list.List
+	tables      map[string]map[string]bool
+	generations map[string]uint64
+}
+
+type lruEntry struct {
+	key       string
+	tableName string
+	rows      [][]interface{}
+	expires   time.Time
+}
+
+// Returns a cache keeping at most size selects for ttl. A ttl of 0 keeps them until they
+// are invalidated or removed to make room.
+func NewLRUCache(size int, ttl time.Duration) *LRUCache {
+	if size <= 0 {
+		panic("Cache size must be positive")
+	}
+
+	return &LRUCache{
+		size:        size,
+		ttl:         ttl,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+		tables:      make(map[string]map[string]bool),
+		generations: make(map[string]uint64),
+	}
+}
+
+func (c *LRUCache) Get(key string) ([][]interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*lruEntry)
+
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.remove(element)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+
+	return entry.rows, true
+}
+
+func (c *LRUCache) Generation(tableName string) uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.generations[tableName]
+}
+
+func (c *LRUCache) Set(key string, tableName string, generation uint64, rows [][]interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// the rows have been selected before a write of the table
+	if c.generations[tableName] != generation {
+		return
+	}
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	entry := &lruEntry{key: key, tableName: tableName, rows: rows, expires: time.Now().Add(c.ttl)}
+	c.entries[key] = c.order.PushFront(entry)
+
+	if c.tables[tableName] == nil {
+		c.tables[tableName] = make(map[string]bool)
+	}
+	c.tables[tableName][key] = true
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRUCache) InvalidateTable(tableName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generations[tableName]++
+
+	for key := range c.tables[tableName] {
+		c.remove(c.entries[key])
+	}
+}
+
+// Returns the number of selects in the cache
+func (c *LRUCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *LRUCache) remove(element *list.Element) {
+	entry := element.Value.(*lruEntry)
+
+	c.order.Remove(element)
+	delete(c.entries, entry.key)
+	delete(c.tables[entry.tableName], entry.key)
+
+	if len(c.tables[entry.tableName]) == 0 {
+		delete(c.tables, entry.tableName)
+	}
+}
+
+// Sets the cache used for the selects of tables that have been set to be cached with
+// SetCached(). Writes of the table through the connection invalidate its cached selects,
+// statements executed with Exec() do not, use InvalidateCache() for them. Selects within a
+// transaction or with a lock mode are not cached. Selects through Primary() skip the cache and
+// only rows read from the primary are stored, not those of a replica. A nil cache turns caching off.
+func (dbc *DbConnection) SetCache(cache Cache) {
+	dbc.cache = cache
+}
+
+// Removes the cached selects of a table
+func (dbc *DbConnection) InvalidateCache(tableName string) {
+	if dbc.cache != nil {
+		dbc.cache.InvalidateTable(tableName)
+	}
+}
+
+// Sets the selects of the table to be cached by the cache of the connection, see SetCache().
+// Meant for lookup tables that are read much more often than they are written.
+func (t *DbTable) SetCached(cached bool) {
+	t.cached = cached
+}
+
+// Invalidates the cached selects of a table written through the connection. Within a
+// transaction the table is invalidated again when the transaction ends, so that selects
+// cached in the meantime don't keep the values from before the transaction.
+func (dbc *DbConnection) tableWritten(tableName string) {
+	if dbc.cache == nil {
+		return
+	}
+
+	dbc.cache.InvalidateTable(tableName)
+
+	if dbc.txTables != nil {
+		dbc.txTables[tableName] = true
+	}
+}
+
+// Invalidates the tables written within a transaction after it has ended
+func (dbc *DbConnection) invalidateTxTables() {
+	if dbc.cache == nil {
+		return
+	}
+
+	for tableName := range dbc.txTables {
+		dbc.cache.InvalidateTable(tableName)
+	}
+}
+
+// Returns the scanned rows of a select, from the cache if the table is cached
+func (t DbTable) queryValues(dbc *DbConnection, queryStr string, args []interface{}) ([][]interface{}, error) {
+	var key string
+	var generation uint64
+	var valueRows [][]interface{}
+
+	cached := t.cached && dbc.cache != nil && !dbc.InTransaction() && t.lockMode == LockNone
+
+	if cached {
+		key = queryStr + fmt.Sprintf(" %#v", args)
+		// read before the select, a write invalidating the table during the select drops its rows
+		generation = dbc.cache.Generation(t.tableName)
+	}
+
+	// reads through Primary() must see the latest writes, the cache may be behind
+	if cached && !dbc.primaryReads {
+		if valueRows, ok := dbc.cache.Get(key); ok {
+			return valueRows, nil
+		}
+	}
+
+	readc := dbc.readConnection(t.lockMode)
+	rows, err := readc.query(queryStr, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		values, dest := t.newScanValues()
+
+		err = rows.Scan(dest...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		valueRows = append(valueRows, values)
+	}
+
+	err = rows.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// rows of a lagging replica would stay cached after the write invalidating them
+	if cached && readc == dbc {
+		dbc.cache.Set(key, t.tableName, generation, valueRows)
+	}
+
+	return valueRows, nil
+}
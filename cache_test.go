@@ -0,0 +1,235 @@
+package dbop
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	cache := NewLRUCache(2, 0)
+	rows := [][]interface{}{{int64(1), "a"}}
+
+	cache.Set("a1", "a", 0, rows)
+	cache.Set("b1", "b", 0, rows)
+
+	if _, ok := cache.Get("a1"); !ok {
+		t.Fatal("a1 not cached")
+	}
+
+	// b1 is the least recently used
+	cache.Set("a2", "a", 0, rows)
+
+	if _, ok := cache.Get("b1"); ok || cache.Len() != 2 {
+		t.Errorf("b1 was not removed, %d entries", cache.Len())
+	}
+
+	cache.Set("b2", "b", 0, rows)
+	cache.InvalidateTable("a")
+
+	if _, ok := cache.Get("a2"); ok || cache.Len() != 1 {
+		t.Errorf("table a was not invalidated, %d entries", cache.Len())
+	}
+
+	if got, ok := cache.Get("b2"); !ok || len(got) != 1 {
+		t.Errorf("b2 got %v", got)
+	}
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	cache := NewLRUCache(10, 10*time.Millisecond)
+	cache.Set("a1", "a", 0, nil)
+
+	if _, ok := cache.Get("a1"); !ok {
+		t.Fatal("a1 not cached")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := cache.Get("a1"); ok || cache.Len() != 0 {
+		t.Errorf("a1 did not expire")
+	}
+}
+
+func TestLRUCacheGeneration(t *testing.T) {
+	cache := NewLRUCache(10, 0)
+	generation := cache.Generation("a")
+
+	cache.InvalidateTable("a")
+
+	if cache.Generation("a") == generation || cache.Generation("b") != 0 {
+		t.Errorf("got generations %d, %d", cache.Generation("a"), cache.Generation("b"))
+	}
+
+	// rows selected before the invalidation are dropped
+	cache.Set("a1", "a", generation, nil)
+
+	if _, ok := cache.Get("a1"); ok {
+		t.Errorf("rows of an old generation have been cached")
+	}
+
+	cache.Set("a1", "a", cache.Generation("a"), nil)
+
+	if _, ok := cache.Get("a1"); !ok {
+		t.Errorf("rows of the current generation have not been cached")
+	}
+}
+
+// Runs a function before the rows of a select are stored
+type interleavedCache struct {
+	*LRUCache
+	beforeSet func()
+}
+
+func (c *interleavedCache) Set(key string, tableName string, generation uint64, rows [][]interface{}) {
+	if c.beforeSet != nil {
+		beforeSet := c.beforeSet
+		c.beforeSet = nil
+		beforeSet()
+	}
+
+	c.LRUCache.Set(key, tableName, generation, rows)
+}
+
+func TestCachedSelectConcurrentWrite(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var countries DbTable
+	createTestTable(t, dbc, &countries, NewTableSchema("countries", Column{Name: "code", Type: "CHAR", FieldAttr: FieldAttr{Size: 2}}))
+	countries.SetCached(true)
+
+	cache := &interleavedCache{LRUCache: NewLRUCache(10, 0)}
+	dbc.SetCache(cache)
+
+	// the select has read the rows when the insert invalidates the table
+	cache.beforeSet = func() {
+		writer := countries.newTableInstance()
+		writer.SetFieldValue("code", "de")
+
+		if err := writer.DoInsert(dbc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, err := countries.DoSelect(dbc)
+
+	if err != nil || len(rows) != 0 {
+		t.Fatalf("got %d rows, %v", len(rows), err)
+	}
+
+	rows, err = countries.DoSelect(dbc)
+
+	if err != nil || len(rows) != 1 {
+		t.Errorf("got %d rows after the insert, the rows selected before it have been cached", len(rows))
+	}
+}
+
+func TestCachedSelect(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var countries DbTable
+	createTestTable(t, dbc, &countries, NewTableSchema("countries", Column{Name: "code", Type: "CHAR", FieldAttr: FieldAttr{Size: 2}}))
+	countries.SetCached(true)
+
+	cache := NewLRUCache(10, 0)
+	dbc.SetCache(cache)
+
+	countries.SetFieldValue("code", "de")
+
+	if err := countries.DoInsert(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	selectCount := func(conn *DbConnection) int {
+		countries.ClearFields()
+		rows, err := countries.DoSelect(conn)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return len(rows)
+	}
+
+	if n := selectCount(dbc); n != 1 {
+		t.Fatalf("got %d rows", n)
+	}
+
+	// not tracked, the cached rows are returned
+	if _, err := dbc.Exec("INSERT INTO countries (code) VALUES ('fr')"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := selectCount(dbc); n != 1 {
+		t.Errorf("got %d rows from the cache", n)
+	}
+
+	if n := selectCount(dbc.Primary()); n != 2 {
+		t.Errorf("got %d rows through Primary()", n)
+	}
+
+	// the read from the primary replaced the cached rows
+	if n := selectCount(dbc); n != 2 {
+		t.Errorf("got %d rows after the read from the primary", n)
+	}
+
+	countries.SetFieldValue("code", "it")
+
+	if err := countries.DoInsert(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := selectCount(dbc); n != 3 {
+		t.Errorf("got %d rows after an insert", n)
+	}
+}
+
+func TestCachedSelectReplica(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var replica DbConnection
+
+	if err := replica.OpenDriver("sqlite3", filepath.Join(t.TempDir(), "replica.db"), false, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var countries, replicaCountries DbTable
+	schema := NewTableSchema("countries", Column{Name: "code", Type: "CHAR", FieldAttr: FieldAttr{Size: 2}})
+	createTestTable(t, dbc, &countries, schema)
+	createTestTable(t, &replica, &replicaCountries, schema)
+
+	if err := dbc.AddReplica(replica.connection); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewLRUCache(10, 0)
+	dbc.SetCache(cache)
+	countries.SetCached(true)
+
+	countries.SetFieldValue("code", "de")
+
+	if err := countries.DoInsert(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	// the replica lags behind, its rows must not be cached
+	cached := cache.Len()
+	countries.ClearFields()
+	rows, err := countries.DoSelect(dbc)
+
+	if err != nil || len(rows) != 0 || cache.Len() != cached {
+		t.Errorf("got %d rows from the replica, %v, %d cached selects", len(rows), err, cache.Len())
+	}
+
+	rows, err = countries.DoSelect(dbc.Primary())
+
+	if err != nil || len(rows) != 1 || cache.Len() != cached+1 {
+		t.Errorf("got %d rows from the primary, %v, %d cached selects", len(rows), err, cache.Len())
+	}
+
+	rows, err = countries.DoSelect(dbc)
+
+	if err != nil || len(rows) != 1 {
+		t.Errorf("got %d cached rows, %v", len(rows), err)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/mcomsis/dbop"
+	_ "github.com/ziutek/mymysql/godrv"
+)
+
+func runGen(args []string) error {
+	flags := flag.NewFlagSet("gen", flag.ExitOnError)
+	driver := flags.String("driver", "mymysql", "database/sql driver name, mymysql or mysql")
+	dsn := flags.String("dsn", "", "connection string of the database to generate from")
+	pkg := flags.String("pkg", "models", "package name of the generated files")
+	out := flags.String("out", ".", "directory the files are written to")
+	tables := flags.String("tables", "", "comma separated list of tables, all tables if empty")
+	flags.Parse(args)
+
+	if len(*dsn) == 0 {
+		return fmt.Errorf("-dsn is required")
+	}
+
+	// the column types, the auto_increment detection and the linked drivers are MySQL only
+	if *driver != "mysql" && *driver != "mymysql" {
+		return fmt.Errorf("-driver %s is not supported, gen reads MySQL databases with the mysql or mymysql driver", *driver)
+	}
+
+	var dbcon dbop.DbConnection
+	err := dbcon.OpenDriver(*driver, *dsn, false, "")
+
+	if err != nil {
+		return err
+	}
+
+	defer dbcon.Close()
+
+	var tableNames []string
+
+	if len(*tables) != 0 {
+		tableNames = strings.Split(*tables, ",")
+	} else {
+		tableNames, err = dbcon.TableNames()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.MkdirAll(*out, 0755)
+
+	if err != nil {
+		return err
+	}
+
+	// table names differing only in case or separators map to the same file
+	fileTables := make(map[string]string)
+
+	for _, tableName := range tableNames {
+		tableName = strings.TrimSpace(tableName)
+		fileName := filepath.Join(*out, strings.ToLower(goName(tableName))+"_table.go")
+
+		if other, ok := fileTables[fileName]; ok {
+			return fmt.Errorf("tables %s and %s are both written to %s", other, tableName, fileName)
+		}
+
+		fileTables[fileName] = tableName
+
+		columns, err := dbcon.TableColumns(tableName)
+
+		if err != nil {
+			return err
+		}
+
+		if len(columns) == 0 {
+			return fmt.Errorf("table %s does not exist", tableName)
+		}
+
+		src, err := generateTable(*pkg, tableName, columns)
+
+		if err != nil {
+			return err
+		}
+
+		err = os.WriteFile(fileName, src, 0644)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s\n", fileName)
+	}
+
+	return nil
+}
+
+// Converts a table or column name to an exported Go identifier, e.g. registered_at to RegisteredAt
+func goName(name string) string {
+	var b strings.Builder
+	upper := true
+
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+
+		if upper {
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	ident := b.String()
+
+	if len(ident) == 0 || unicode.IsDigit(rune(ident[0])) {
+		ident = "F" + ident
+	}
+
+	return ident
+}
+
+// Names of the methods of dbop.DbTable and of the embedded DbTable field, accessors must not shadow them
+func tableMethodNames() map[string]bool {
+	names := map[string]bool{"DbTable": true}
+	tblType := reflect.TypeOf(&dbop.DbTable{})
+
+	for i := 0; i < tblType.NumMethod(); i++ {
+		names[tblType.Method(i).Name] = true
+	}
+
+	return names
+}
+
+// Returns the name or, if it is taken, the name with the suffix and a number if needed
+func uniqueName(name string, suffix string, taken func(name string) bool) string {
+	if !taken(name) {
+		return name
+	}
+
+	candidate := name + suffix
+
+	for n := 2; taken(candidate); n++ {
+		candidate = name + suffix + strconv.Itoa(n)
+	}
+
+	return candidate
+}
+
+// Returns the field type used in the table definition for a database column
+func fieldType(column dbop.ColumnInfo) (string, error) {
+	switch column.Type {
+	case "BIT", "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "DECIMAL", "FLOAT",
+		"DOUBLE", "YEAR", "DATE", "DATETIME", "TIMESTAMP", "TIME", "CHAR", "VARCHAR",
+		"BINARY", "VARBINARY", "TINYBLOB", "TINYTEXT", "BLOB", "TEXT", "MEDIUMBLOB",
+		"MEDIUMTEXT", "LONGBLOB", "LONGTEXT", "ENUM", "SET":
+		return column.Type, nil
+	}
+
+	return "", fmt.Errorf("column %s has the unsupported type %s", column.Name, column.Type)
+}
+
+// Returns the Go type of the typed accessors and the expressions that convert from and to the field value string
+func accessorType(column dbop.ColumnInfo) (goType string, parse string, formatValue string) {
+	switch column.Type {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR":
+		if column.Unsigned {
+			return "uint64", "strconv.ParseUint(%s, 10, 64)", "strconv.FormatUint(%s, 10)"
+		}
+		return "int64", "strconv.ParseInt(%s, 10, 64)", "strconv.FormatInt(%s, 10)"
+
+	case "FLOAT", "DOUBLE":
+		return "float64", "strconv.ParseFloat(%s, 64)", "strconv.FormatFloat(%s, 'f', -1, 64)"
+	}
+
+	return "string", "", ""
+}
+
+func generateTable(pkg string, tableName string, columns []dbop.ColumnInfo) ([]byte, error) {
+	var buf bytes.Buffer
+	var fieldNames, fieldTypes []string
+	var fieldColumns []dbop.ColumnInfo
+	var hasRecId, recIdAutoInc, usesStrconv bool
+
+	typeName := goName(tableName)
+	methodNames := tableMethodNames()
+
+	// package level names of the file, column constants must not clash with them or each other
+	constNames := map[string]bool{typeName: true, "New" + typeName: true, typeName + "TableName": true}
+	constTaken := func(name string) bool { return constNames[name] }
+	accessorTaken := func(name string) bool { return methodNames[name] || methodNames["Set"+name] }
+
+	for i, column := range columns {
+		if column.Name == "recid" {
+			if i != 0 {
+				return nil, fmt.Errorf("table %s: recid must be the first column", tableName)
+			}
+			hasRecId = true
+			recIdAutoInc = strings.Contains(strings.ToLower(column.Extra), "auto_increment")
+			continue
+		}
+
+		fType, err := fieldType(column)
+
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %v", tableName, err)
+		}
+
+		if goType, _, _ := accessorType(column); goType != "string" {
+			usesStrconv = true
+		}
+
+		fieldNames = append(fieldNames, column.Name)
+		fieldTypes = append(fieldTypes, fType)
+		fieldColumns = append(fieldColumns, column)
+	}
+
+	fmt.Fprintf(&buf, "// Code generated by dbop gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&buf, "import (\n")
+	if usesStrconv {
+		fmt.Fprintf(&buf, "\t\"strconv\"\n\n")
+	}
+	fmt.Fprintf(&buf, "\t\"github.com/mcomsis/dbop\"\n)\n\n")
+
+	fmt.Fprintf(&buf, "// Field names of the %s table\nconst (\n", tableName)
+	fmt.Fprintf(&buf, "\t%sTableName = %q\n", typeName, tableName)
+	columnConsts := make([]string, len(fieldNames))
+	for i, name := range fieldNames {
+		columnConsts[i] = uniqueName(typeName+goName(name), "Column", constTaken)
+		constNames[columnConsts[i]] = true
+		fmt.Fprintf(&buf, "\t%s = %q\n", columnConsts[i], name)
+	}
+	fmt.Fprintf(&buf, ")\n\n")
+
+	fmt.Fprintf(&buf, "// %s is the table definition of the %s table\n", typeName, tableName)
+	fmt.Fprintf(&buf, "type %s struct {\n\tdbop.DbTable\n}\n\n", typeName)
+
+	fmt.Fprintf(&buf, "// Returns an initiated %s table\n", typeName)
+	fmt.Fprintf(&buf, "func New%s() %s {\n", typeName, typeName)
+	fmt.Fprintf(&buf, "\tvar tbl %s\n\n", typeName)
+	fmt.Fprintf(&buf, "\tschema := dbop.NewTableSchema(%sTableName,\n", typeName)
+	if hasRecId {
+		fmt.Fprintf(&buf, "\t\tdbop.Column{Name: \"recid\", Type: \"BIGINT\", FieldAttr: dbop.FieldAttr{NotNull: true}, AutoIncrement: %v, PrimaryKey: true},\n", recIdAutoInc)
+	}
+	for i, column := range fieldColumns {
+		fmt.Fprintf(&buf, "\t\tdbop.Column{Name: %s, Type: %q, FieldAttr: dbop.FieldAttr{Size: %d, Scale: %d, NotNull: %v, Default: %q, HasDefault: %v, Unsigned: %v}",
+			columnConsts[i], fieldTypes[i], column.Size, column.Scale, !column.Nullable, column.Default, column.HasDefault, column.Unsigned)
+		if len(column.Comment) != 0 {
+			fmt.Fprintf(&buf, ", Comment: %q", column.Comment)
+		}
+		fmt.Fprintf(&buf, "},\n")
+	}
+	fmt.Fprintf(&buf, "\t)\n\n")
+	fmt.Fprintf(&buf, "\terr := tbl.InitSchema(schema)\n\n\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	fmt.Fprintf(&buf, "\n\treturn tbl\n}\n")
+
+	for i, column := range fieldColumns {
+		accessor := uniqueName(goName(column.Name), "Field", accessorTaken)
+		methodNames[accessor] = true
+		methodNames["Set"+accessor] = true
+
+		constName := columnConsts[i]
+		goType, parse, formatValue := accessorType(column)
+
+		if goType == "string" {
+			fmt.Fprintf(&buf, "\n// Returns the value of the %s field\n", column.Name)
+			fmt.Fprintf(&buf, "func (t %s) %s() string {\n\treturn t.GetFieldValue(%s)\n}\n", typeName, accessor, constName)
+			fmt.Fprintf(&buf, "\n// Sets the value of the %s field\n", column.Name)
+			fmt.Fprintf(&buf, "func (t *%s) Set%s(value string) {\n\tt.SetFieldValue(%s, value)\n}\n", typeName, accessor, constName)
+			continue
+		}
+
+		fmt.Fprintf(&buf, "\n// Returns the value of the %s field, an error if the value is empty or not a valid %s\n", column.Name, goType)
+		fmt.Fprintf(&buf, "func (t %s) %s() (%s, error) {\n\treturn %s\n}\n", typeName, accessor, goType,
+			fmt.Sprintf(parse, "t.GetFieldValue("+constName+")"))
+		fmt.Fprintf(&buf, "\n// Sets the value of the %s field\n", column.Name)
+		fmt.Fprintf(&buf, "func (t *%s) Set%s(value %s) {\n\tt.SetFieldValue(%s, %s)\n}\n", typeName, accessor, goType, constName,
+			fmt.Sprintf(formatValue, "value"))
+	}
+
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/mcomsis/dbop"
+)
+
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"registered_at": "RegisteredAt",
+		"user-roles":    "UserRoles",
+		"UserRoles":     "UserRoles",
+		"2fa":           "F2fa",
+		"_":             "F",
+	}
+
+	for name, want := range tests {
+		if got := goName(name); got != want {
+			t.Errorf("goName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGenerateTable(t *testing.T) {
+	columns := []dbop.ColumnInfo{
+		{Name: "recid", Type: "BIGINT", Extra: "auto_increment", Unsigned: true},
+		{Name: "name", Type: "VARCHAR", Size: 45, Comment: "display name"},
+		{Name: "rating", Type: "INT", Nullable: true},
+		{Name: "weight", Type: "DOUBLE", Nullable: true},
+		{Name: "table_name", Type: "VARCHAR", Size: 45},
+		{Name: "db_table", Type: "VARCHAR", Size: 45},
+		{Name: "validate", Type: "VARCHAR", Size: 45},
+	}
+
+	src, err := generateTable("models", "users", columns)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "users_table.go", src, 0); err != nil {
+		t.Fatalf("generated source does not parse: %v\n%s", err, src)
+	}
+
+	// gofmt aligns the constants, compare with single spaces
+	code := strings.Join(strings.Fields(string(src)), " ")
+
+	for _, want := range []string{
+		"package models",
+		"\"strconv\"",
+		"UsersTableName = \"users\"",
+		"UsersTableNameColumn = \"table_name\"",
+		"AutoIncrement: true, PrimaryKey: true",
+		"Comment: \"display name\"",
+		"func (t Users) Name() string",
+		"func (t Users) Rating() (int64, error)",
+		"func (t *Users) SetWeight(value float64)",
+		"func (t Users) DbTableField() string",
+		"func (t Users) ValidateField() string",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated source does not contain %q\n%s", want, src)
+		}
+	}
+
+	for _, unwanted := range []string{"func (t Users) DbTable()", "func (t Users) Validate()"} {
+		if strings.Contains(code, unwanted) {
+			t.Errorf("generated source contains %q", unwanted)
+		}
+	}
+}
+
+func TestGenerateTableErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []dbop.ColumnInfo
+	}{
+		{"recid not first", []dbop.ColumnInfo{{Name: "name", Type: "VARCHAR"}, {Name: "recid", Type: "BIGINT"}}},
+		{"unsupported type", []dbop.ColumnInfo{{Name: "shape", Type: "GEOMETRY"}}},
+	}
+
+	for _, test := range tests {
+		if _, err := generateTable("models", "users", test.columns); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
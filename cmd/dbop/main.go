@@ -0,0 +1,39 @@
+// Command dbop contains tools for working with dbop table definitions.
+//
+//	dbop gen -dsn test/root/ -pkg models -out ./models
+//	dbop gen -driver mysql -dsn 'root@tcp(localhost:3306)/test' -pkg models
+//
+// gen connects to a database and writes a Go source file per table with constants for the
+// field names, a constructor calling InitTable and typed accessors for the field values.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: dbop <command> [flags]\n\ncommands:\n")
+	fmt.Fprintf(os.Stderr, "  gen    generate table definitions from a database\n")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+
+	switch os.Args[1] {
+	case "gen":
+		err = runGen(os.Args[2:])
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dbop %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
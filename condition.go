@@ -0,0 +1,218 @@
+package dbop
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A where clause built with Where() and extended with And() and Or(), e.g.
+//
+//	dbop.Where("yr", "=", "2011").And("rating", ">", "3").Or("role", "IN", "1", "2")
+//
+// Conditions are joined in the order they are added and follow the sql precedence, AND before
+// OR. Use AndGroup() and OrGroup() for parentheses. The same conditions are used for selects,
+// updates and deletes. Supported operators are =, <>, !=, <, <=, >, >=, LIKE, NOT LIKE,
+// IN, NOT IN, BETWEEN, IS NULL and IS NOT NULL.
+type Condition struct {
+	terms []conditionTerm
+}
+
+type conditionTerm struct {
+	join      string // AND or OR, empty for the first term
+	fieldName string
+	operator  string
+	values    []string
+	group     *Condition
+}
+
+// Returns a new condition comparing a field with one or more values. IN and NOT IN take any
+// number of values, BETWEEN takes two, IS NULL and IS NOT NULL none, all other operators one.
+func Where(fieldName string, operator string, values ...string) *Condition {
+	c := &Condition{}
+	return c.add("", fieldName, operator, values)
+}
+
+// Returns a new condition with a parenthesized group of conditions as its first term
+func WhereGroup(group *Condition) *Condition {
+	c := &Condition{}
+	return c.addGroup("", group)
+}
+
+// Adds a condition joined with AND
+func (c *Condition) And(fieldName string, operator string, values ...string) *Condition {
+	return c.add("AND", fieldName, operator, values)
+}
+
+// Adds a condition joined with OR
+func (c *Condition) Or(fieldName string, operator string, values ...string) *Condition {
+	return c.add("OR", fieldName, operator, values)
+}
+
+// Adds a parenthesized group of conditions joined with AND
+func (c *Condition) AndGroup(group *Condition) *Condition {
+	return c.addGroup("AND", group)
+}
+
+// Adds a parenthesized group of conditions joined with OR
+func (c *Condition) OrGroup(group *Condition) *Condition {
+	return c.addGroup("OR", group)
+}
+
+func (c *Condition) add(join string, fieldName string, operator string, values []string) *Condition {
+	if len(c.terms) == 0 {
+		join = ""
+	}
+
+	operator = strings.ToUpper(strings.Join(strings.Fields(operator), " "))
+	c.terms = append(c.terms, conditionTerm{join: join, fieldName: fieldName, operator: operator, values: values})
+
+	return c
+}
+
+func (c *Condition) addGroup(join string, group *Condition) *Condition {
+	if len(c.terms) == 0 {
+		join = ""
+	}
+
+	c.terms = append(c.terms, conditionTerm{join: join, group: group})
+
+	return c
+}
+
+// Returns true if the condition has no terms
+func (c *Condition) IsEmpty() bool {
+	return c == nil || len(c.terms) == 0
+}
+
+// Builds the sql of the condition for a table, adding the values to the statement arguments
+func (c *Condition) buildStr(t DbTable, args *stmtArgs) (string, error) {
+	var whereStr string
+
+	if c.IsEmpty() {
+		return "", fmt.Errorf("Empty where condition")
+	}
+
+	for _, term := range c.terms {
+		if len(term.join) != 0 {
+			whereStr = whereStr + " " + term.join + " "
+		}
+
+		if term.group != nil {
+			groupStr, err := term.group.buildStr(t, args)
+
+			if err != nil {
+				return "", err
+			}
+
+			whereStr = whereStr + "(" + groupStr + ")"
+			continue
+		}
+
+		termStr, err := term.buildStr(t, args)
+
+		if err != nil {
+			return "", err
+		}
+
+		whereStr = whereStr + termStr
+	}
+
+	return whereStr, nil
+}
+
+func (term conditionTerm) buildStr(t DbTable, args *stmtArgs) (string, error) {
+	var fieldType string
+
+	if fId := t.fieldId(term.fieldName); fId >= 0 {
+		fieldType = t.columns[fId].Type
+	} else if term.fieldName == "recid" && t.recid.Exists {
+		fieldType = "BIGINT"
+	} else {
+		return "", fmt.Errorf("Unknown field %s in where condition", term.fieldName)
+	}
+
+	fieldStr, err := t.quotedField(args.dialect, term.fieldName)
+
+	if err != nil {
+		return "", err
+	}
+
+	valueCount := 1
+
+	switch term.operator {
+	case "=", "<>", "!=", "<", "<=", ">", ">=", "LIKE", "NOT LIKE":
+	case "IS NULL", "IS NOT NULL":
+		valueCount = 0
+	case "BETWEEN":
+		valueCount = 2
+	case "IN", "NOT IN":
+		valueCount = len(term.values)
+		if valueCount == 0 {
+			return "", fmt.Errorf("%s for field %s needs at least one value", term.operator, term.fieldName)
+		}
+	default:
+		return "", fmt.Errorf("Unknown operator %s for field %s", term.operator, term.fieldName)
+	}
+
+	if len(term.values) != valueCount {
+		return "", fmt.Errorf("%s for field %s needs %v values, got %v", term.operator, term.fieldName, valueCount, len(term.values))
+	}
+
+	placeholders := make([]string, len(term.values))
+
+	for i, value := range term.values {
+		placeholders[i], err = args.addValue(value, fieldType)
+
+		if err != nil {
+			return "", fmt.Errorf("Field %s: %v", term.fieldName, err)
+		}
+	}
+
+	switch term.operator {
+	case "IS NULL", "IS NOT NULL":
+		return fieldStr + " " + term.operator, nil
+	case "BETWEEN":
+		return fieldStr + " BETWEEN " + placeholders[0] + " AND " + placeholders[1], nil
+	case "IN", "NOT IN":
+		return fieldStr + " " + term.operator + " (" + strings.Join(placeholders, ", ") + ")", nil
+	}
+
+	return fieldStr + " " + term.operator + " " + placeholders[0], nil
+}
+
+// Returns the equality conditions for the field values that have been set and for the recid
+// if it has been set. Returns nil if nothing has been set. Fields set to an expression can't be
+// used as conditions.
+func (t DbTable) fieldCondition() (*Condition, error) {
+	var where *Condition
+
+	for fId, isSet := range t.fieldValueSet {
+		if isSet {
+			if t.fieldExprs[fId] != nil {
+				return nil, fmt.Errorf("Field %s is set to an expression and can't be used as a condition", t.columns[fId].Name)
+			}
+
+			if where == nil {
+				where = Where(t.columns[fId].Name, "=", t.fieldValue[fId])
+			} else {
+				where.And(t.columns[fId].Name, "=", t.fieldValue[fId])
+			}
+		}
+	}
+
+	if t.recid.Exists && t.recid.IsSet {
+		if where == nil {
+			where = Where("recid", "=", strconv.FormatUint(t.recid.Value, 10))
+		} else {
+			where.And("recid", "=", strconv.FormatUint(t.recid.Value, 10))
+		}
+	}
+
+	return where, nil
+}
+
+// Returns the condition matching the selected record by its recid
+func (t DbTable) recIdCondition() *Condition {
+	return Where("recid", "=", strconv.FormatUint(t.recid.Value, 10))
+}
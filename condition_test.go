@@ -0,0 +1,94 @@
+package dbop
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConditionBuildStr(t *testing.T) {
+	tbl := testUsersTable(t)
+
+	where := Where("name", "like", "a%").And("rating", "BETWEEN", "1", "2").
+		OrGroup(Where("active", "in", "1", "2", "3").And("note", "is  not null")).And("recid", "=", "7")
+
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{MySQL{}, "`users`.`name` LIKE ? AND `users`.`rating` BETWEEN ? AND ? OR " +
+			"(`users`.`active` IN (?, ?, ?) AND `users`.`note` IS NOT NULL) AND `users`.`recid` = ?"},
+		{PostgreSQL{}, `"users"."name" LIKE $1 AND "users"."rating" BETWEEN $2 AND $3 OR ` +
+			`("users"."active" IN ($4, $5, $6) AND "users"."note" IS NOT NULL) AND "users"."recid" = $7`},
+	}
+
+	for _, test := range tests {
+		args := stmtArgs{dialect: test.dialect}
+		whereStr, err := where.buildStr(tbl, &args)
+
+		if err != nil {
+			t.Errorf("%s: %v", test.dialect.Name(), err)
+			continue
+		}
+
+		if whereStr != test.want {
+			t.Errorf("%s: got %s, want %s", test.dialect.Name(), whereStr, test.want)
+		}
+
+		if want := []interface{}{"a%", "1", "2", "1", "2", "3", "7"}; !reflect.DeepEqual(args.args, want) {
+			t.Errorf("%s: got arguments %v, want %v", test.dialect.Name(), args.args, want)
+		}
+	}
+}
+
+func TestConditionErrors(t *testing.T) {
+	tbl := testUsersTable(t)
+
+	tests := map[string]*Condition{
+		"empty":            nil,
+		"unknown field":    Where("email", "=", "a"),
+		"unknown operator": Where("name", "==", "a"),
+		"missing value":    Where("name", "="),
+		"between":          Where("rating", "BETWEEN", "1"),
+		"empty in":         Where("active", "NOT IN"),
+		"is null value":    Where("note", "IS NULL", "a"),
+		"in group":         Where("name", "=", "a").AndGroup(Where("email", "=", "b")),
+	}
+
+	for name, where := range tests {
+		args := stmtArgs{dialect: MySQL{}}
+
+		if _, err := where.buildStr(tbl, &args); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestFieldCondition(t *testing.T) {
+	tbl := testUsersTable(t)
+
+	if where, _ := tbl.fieldCondition(); where != nil {
+		t.Errorf("got a condition without set fields")
+	}
+
+	tbl.SetFieldValue("name", "ann")
+	tbl.SetRecId(7)
+
+	where, err := tbl.fieldCondition()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := stmtArgs{dialect: MySQL{}}
+	whereStr, err := where.buildStr(tbl, &args)
+
+	if want := "`users`.`name` = ? AND `users`.`recid` = ?"; err != nil || whereStr != want {
+		t.Errorf("got %s, %v, want %s", whereStr, err, want)
+	}
+
+	tbl.IncrementField("rating", "1")
+
+	if _, err = tbl.fieldCondition(); err == nil {
+		t.Errorf("no error for a field set to an expression")
+	}
+}
@@ -4,45 +4,14 @@
 package dbop
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	_ "github.com/ziutek/mymysql/godrv"
 	"strconv"
+	"strings"
+	"time"
 )
 
-func toStmtStr(value string, valueType string) string {
-	switch valueType {
-	case "BIT", "TINYINT", "BOOL", "BOOLEAN", "SMALLINT", "MEDIUMINT",
-		"INT", "INTEGER", "BIGINT", "SERIAL", "DECIMAL", "DEC", "FLOAT",
-		"DOUBLE", "YEAR":
-		return value
-
-	case "DATE", "DATETIME", "TIMESTAMP", "TIME", "CHAR", "VARCHAR",
-		"BINARY", "VARBINARY", "TINYBLOB", "TINYTEXT", "BLOB", "TEXT",
-		"MEDIUMBLOB", "MEDIUMTEXT", "LONGBLOB", "LONGTEXT", "ENUM", "SET":
-		return "'" + value + "'"
-	}
-
-	return ""
-}
-
-func anytypeToStr(value interface{}) string {
-	switch value.(type) {
-	case int, int8, int16, int32, int64:
-		return strconv.FormatInt(value.(int64), 10)
-	case uint, uint8, uint16, uint32, uint64:
-		return strconv.FormatUint(value.(uint64), 10)
-	case float32, float64:
-		return strconv.FormatFloat(value.(float64), 'f', -1, 32)
-	case bool:
-		return strconv.FormatBool(value.(bool))
-	default:
-		return fmt.Sprintf("%s", value)
-	}
-
-	return ""
-}
-
 func RemoveTimezoneFromStr(value string) string {
 	return value[0 : len(value)-10]
 }
@@ -54,19 +23,27 @@ type RecId struct {
 	IsSet   bool
 }
 
-type DbUpdateField struct {
-	FieldName string
-	Value     string
-}
-
 // Defines the database table base type
 type DbTable struct {
-	tableName     string
-	fieldNames    []string
-	fieldTypes    []string
-	fieldValue    []string
-	fieldValueSet []bool
-	recid         RecId
+	tableName       string
+	columns         []Column
+	fieldValue      []string
+	fieldValueSet   []bool
+	fieldExprs      []*fieldExpr
+	fieldOrig       []string
+	loaded          bool
+	skipUnchanged   bool
+	versionField    string
+	lockMode        LockMode
+	createdField    string
+	updatedField    string
+	softDeleteField string
+	deletedScope    deletedScope
+	hooks           map[HookEvent][]Hook
+	fieldRules      [][]Rule
+	cached          bool
+	recid           RecId
+	indexes         []DbIndex
 }
 
 // Returns the recid value of the table and the IsSet value. IsSet will be true if the
@@ -100,44 +77,55 @@ func (t *DbTable) SetRecId(recId uint64) {
 
 func (t DbTable) newTableInstance() DbTable {
 	var tbl DbTable
-	var fieldNames []string
-	var fieldTypes []string
-	var recid [2]bool
-
-	fieldNames = make([]string, len(t.fieldNames))
-	copy(fieldNames, t.fieldNames)
-
-	fieldTypes = make([]string, len(t.fieldTypes))
-	copy(fieldTypes, t.fieldTypes)
-
-	recid[0] = t.recid.Exists
-	recid[1] = t.recid.AutoInc
 
-	tbl.InitTable(t.tableName, fieldNames, fieldTypes, recid)
+	tbl.initColumns(t.tableName, append([]Column(nil), t.columns...), t.recid)
+	tbl.indexes = t.indexes
+	tbl.skipUnchanged = t.skipUnchanged
+	tbl.versionField = t.versionField
+	tbl.createdField = t.createdField
+	tbl.updatedField = t.updatedField
+	tbl.softDeleteField = t.softDeleteField
+	tbl.deletedScope = t.deletedScope
+	tbl.hooks = copyHooks(t.hooks)
+	tbl.cached = t.cached
+	copy(tbl.fieldRules, t.fieldRules)
 
 	return tbl
 }
 
 // Initiates the base type with info from a specific table in the database.
 // recid is a field and a unique index for that field that can be created on the table
-// for easily performing DoUpdate and DoDelete operations after selecting a single record
+// for easily performing DoUpdate and DoDelete operations after selecting a single record.
+// There is a field for each of the field types, unlike InitSchema() the definition is not checked.
 func (t *DbTable) InitTable(tableName string, fieldNames []string, fieldTypes []string, recid [2]bool) {
-	t.tableName = tableName
-	t.fieldNames = fieldNames
-	t.fieldTypes = fieldTypes
-	t.fieldValue = make([]string, len(fieldTypes))
-	t.fieldValueSet = make([]bool, len(fieldTypes))
-	t.recid.Exists = recid[0]
-	if t.recid.Exists {
-		t.recid.AutoInc = recid[1]
+	columns := make([]Column, len(fieldTypes))
+
+	for fId, fieldType := range fieldTypes {
+		columns[fId].Type = fieldType
+
+		if fId < len(fieldNames) {
+			columns[fId].Name = fieldNames[fId]
+		}
 	}
+
+	t.initColumns(tableName, columns, RecId{Exists: recid[0], AutoInc: recid[1]})
 }
 
 // Resets the table variable for initiating as a different database table
 func (t *DbTable) ResetTable() {
 	t.tableName = ""
-	t.fieldNames = nil
-	t.fieldTypes = nil
+	t.columns = nil
+	t.fieldExprs = nil
+	t.fieldOrig = nil
+	t.loaded = false
+	t.versionField = ""
+	t.createdField = ""
+	t.updatedField = ""
+	t.softDeleteField = ""
+	t.deletedScope = withoutDeleted
+	t.hooks = nil
+	t.fieldRules = nil
+	t.indexes = nil
 	t.recid.AutoInc = false
 	t.recid.Exists = false
 	t.recid.Value = 0
@@ -145,23 +133,40 @@ func (t *DbTable) ResetTable() {
 
 // Returns a slice of all the field names for the initiated table
 func (t DbTable) GetFieldNameList() []string {
-	return t.fieldNames
+	fieldNames := make([]string, len(t.columns))
+
+	for fId, column := range t.columns {
+		fieldNames[fId] = column.Name
+	}
+
+	return fieldNames
 }
 
 // Returns a slice of all the field types for the initiated table
 func (t DbTable) GetFieldTypeList() []string {
-	return t.fieldTypes
+	fieldTypes := make([]string, len(t.columns))
+
+	for fId, column := range t.columns {
+		fieldTypes[fId] = column.Type
+	}
+
+	return fieldTypes
 }
 
 // Return field db type from a field name
 func (t DbTable) GetFieldType(fieldName string) string {
-	for fId, name := range t.fieldNames {
-		if name == fieldName {
-			return t.fieldTypes[fId]
+	return t.GetColumn(fieldName).Type
+}
+
+// Returns the position of the field in the columns or -1 if the field does not exist
+func (t DbTable) fieldId(fieldName string) int {
+	for fId, column := range t.columns {
+		if fieldName == column.Name {
+			return fId
 		}
 	}
 
-	panic("Field not found")
+	return -1
 }
 
 // Returns a the table name of the initiated table
@@ -171,8 +176,8 @@ func (t DbTable) GetTableName() string {
 
 // Returns the value of a field specified by the field name
 func (t DbTable) GetFieldValue(fieldName string) string {
-	for fId, fn := range t.fieldNames {
-		if fieldName == fn {
+	for fId, column := range t.columns {
+		if fieldName == column.Name {
 			return t.fieldValue[fId]
 		}
 	}
@@ -182,10 +187,11 @@ func (t DbTable) GetFieldValue(fieldName string) string {
 
 // Sets the value of a field
 func (t *DbTable) SetFieldValue(fieldName string, fieldValue string) bool {
-	for fId, fn := range t.fieldNames {
-		if fieldName == fn {
+	for fId, column := range t.columns {
+		if fieldName == column.Name {
 			t.fieldValue[fId] = fieldValue
 			t.fieldValueSet[fId] = true
+			t.fieldExprs[fId] = nil
 			return true
 		}
 	}
@@ -198,18 +204,22 @@ func (t *DbTable) ClearFields() {
 	for fId := 0; fId < len(t.fieldValue); fId++ {
 		t.fieldValue[fId] = ""
 		t.fieldValueSet[fId] = false
+		t.fieldExprs[fId] = nil
+		t.fieldOrig[fId] = ""
 	}
 
+	t.loaded = false
 	t.recid.Value = 0
 	t.recid.IsSet = false
 }
 
 // Clears the value of a field specified by the field name
 func (t *DbTable) ClearField(fieldName string) bool {
-	for fId, fn := range t.fieldNames {
-		if fieldName == fn {
+	for fId, column := range t.columns {
+		if fieldName == column.Name {
 			t.fieldValue[fId] = ""
 			t.fieldValueSet[fId] = false
+			t.fieldExprs[fId] = nil
 			return true
 		}
 	}
@@ -226,441 +236,1062 @@ func (t *DbTable) ClearField(fieldName string) bool {
 // Database connection type used when executing a db operation
 type DbConnection struct {
 	connection     *sql.DB
+	tx             *sql.Tx
+	ctx            context.Context
+	auditTable     string
+	cache          Cache
+	stmts          *stmtCache
+	replicas       []*replica
+	replicaPolicy  ReplicaPolicy
+	replicaNext    *uint32
+	primaryReads   bool
+	retryPolicy    RetryPolicy
+	txTables       map[string]bool
+	dialect        Dialect
 	timeZoneOffset string
+	clock          func() time.Time
 	debug          bool
 }
 
-// Opens a database connection
+// Opens a database connection using the mymysql driver. The package does not import any
+// driver, the application must import github.com/ziutek/mymysql/godrv for this to work.
+// Panics if the driver can't be opened. If the database can't be contacted or the time zone
+// fails, the error is printed and the connection is opened without the time zone.
+// Use OpenDriver() to get an error instead.
 func (dbc *DbConnection) Open(connectionStr string, debug bool, timeZoneOffset string) {
-	con, err := sql.Open("mymysql", connectionStr)
+	err := dbc.OpenDriver("mymysql", connectionStr, debug, timeZoneOffset)
 
-	if err != nil {
+	if err == nil {
+		return
+	}
+
+	if len(timeZoneOffset) == 0 {
 		panic(err)
 	}
 
-	dbc.connection = con
-	dbc.timeZoneOffset = timeZoneOffset
-	dbc.debug = debug
+	fmt.Printf("Time zone failed. %s\n", err)
 
-	_, err = dbc.Exec(fmt.Sprintf("set time_zone = '%s'", dbc.timeZoneOffset))
+	con, err := sql.Open("mymysql", connectionStr)
 
 	if err != nil {
-		fmt.Printf("Time zone failed. %s", err)
+		panic(err)
 	}
-}
 
-// Executes a custom sql statement
-func (dbc *DbConnection) Exec(queryStr string) (int64, error) {
-	result, err := dbc.connection.Exec(queryStr)
+	dbc.OpenDB(con, debug, "")
+}
 
-	if err != nil {
-		return 0, err
+// Opens a database connection using any database/sql driver registered under driverName,
+// e.g. "mysql" for github.com/go-sql-driver/mysql. The driver package must be imported by the application.
+// Unless set with SetDialect() before, the dialect is chosen by the driver name.
+// A time zone offset like +02:00 is set as the session time zone of every connection of the
+// pool, through the connection string for the mysql and postgres drivers. The database is
+// contacted right away then, so that a failing time zone is returned.
+func (dbc *DbConnection) OpenDriver(driverName string, connectionStr string, debug bool, timeZoneOffset string) error {
+	if dbc.dialect == nil {
+		dbc.dialect = DialectForDriver(driverName)
 	}
 
-	rowsAffected, _ := result.RowsAffected()
+	if len(timeZoneOffset) == 0 {
+		con, err := sql.Open(driverName, connectionStr)
 
-	return rowsAffected, nil
-}
+		if err != nil {
+			return err
+		}
 
-// Close the database connection
-func (dbc *DbConnection) Close() error {
-	return dbc.connection.Close()
-}
+		return dbc.OpenDB(con, debug, "")
+	}
 
-func (t DbTable) buildSelectStr(firstonly bool, debug bool) (string, error) {
-	var selectStr string
-	var whereStr string
-	var hasWhere bool
+	con, err := openTimeZoneDB(driverName, connectionStr, dbc.dialect, timeZoneOffset)
 
-	selectStr = "SELECT * FROM " + t.tableName
+	if err != nil {
+		return err
+	}
 
-	for fId, isSet := range t.fieldValueSet {
-		if isSet {
-			hasWhere = true
-			if len(whereStr) != 0 {
-				whereStr = whereStr + " AND "
-			}
-			whereStr = whereStr + t.tableName + "." + t.fieldNames[fId] + " = " + toStmtStr(t.fieldValue[fId], t.fieldTypes[fId])
+	err = con.PingContext(dbc.Context())
 
-		}
+	if err != nil {
+		con.Close()
+		return err
 	}
 
-	if t.recid.Exists && t.recid.IsSet {
-		if len(whereStr) != 0 {
-			whereStr = whereStr + " AND "
-		}
-		whereStr = whereStr + t.tableName + ".recid = " + toStmtStr(anytypeToStr(t.recid.Value), "BIGINT")
-		hasWhere = true
-	}
+	return dbc.OpenDB(con, debug, timeZoneOffset)
+}
 
-	if hasWhere {
-		selectStr = selectStr + " WHERE " + whereStr
+// Uses an already opened *sql.DB as the database connection. This allows sharing the connection
+// pool with the rest of the application. Close() will close the passed in *sql.DB.
+// Unless set with SetDialect() before, the MySQL dialect is used.
+// The time zone offset is only used for the time of the connection, see Now(). The pool belongs
+// to the application, its session time zone must be set in its connection string.
+func (dbc *DbConnection) OpenDB(db *sql.DB, debug bool, timeZoneOffset string) error {
+	if db == nil {
+		return fmt.Errorf("db can't be nil")
 	}
 
-	if firstonly {
-		selectStr = selectStr + " LIMIT 1"
+	_, err := offsetLocation(timeZoneOffset)
+
+	if err != nil {
+		return err
 	}
 
-	// for debugging
-	if debug {
-		fmt.Printf("%v\n", selectStr)
+	if dbc.dialect == nil {
+		dbc.dialect = MySQL{}
 	}
 
-	return selectStr, nil
+	dbc.connection = db
+	dbc.timeZoneOffset = timeZoneOffset
+	dbc.debug = debug
+
+	return nil
 }
 
-// Builds and executes a select statement based on the field values that have been set using
-// using the SetFieldValue() function. Will return true if successfull and will populate the
-// field values for the variable called from. All fields returned by the db will be populated,
-// but fields will be considered not set. Selects only the first line from the table.
-func (t *DbTable) DoSelectFirstonly(dbc *DbConnection) error {
-	var offset int
+// Sets the sql dialect used to build statements. Call before opening the connection to
+// override the dialect chosen by the driver name.
+func (dbc *DbConnection) SetDialect(dialect Dialect) {
+	dbc.dialect = dialect
+}
 
-	queryStr, err := t.buildSelectStr(true, dbc.debug)
+// Returns the sql dialect of the connection
+func (dbc *DbConnection) Dialect() Dialect {
+	return dbc.dialect
+}
 
-	if err != nil {
-		return err
-	}
+// Returns the underlying *sql.DB of the connection
+func (dbc *DbConnection) DB() *sql.DB {
+	return dbc.connection
+}
 
-	row := dbc.connection.QueryRow(queryStr)
+// Returns a copy of the connection executing its statements with the context. The context
+// also carries the actor recorded in the audit trail, see WithActor().
+func (dbc *DbConnection) WithContext(ctx context.Context) *DbConnection {
+	ctxc := *dbc
+	ctxc.ctx = ctx
 
-	fieldCount := len(t.fieldNames)
+	return &ctxc
+}
 
-	if t.recid.Exists {
-		fieldCount = fieldCount + 1
+// Returns the context of the connection, context.Background() if none has been set
+func (dbc *DbConnection) Context() context.Context {
+	if dbc.ctx == nil {
+		return context.Background()
 	}
 
-	fields := make([]interface{}, fieldCount)
-	fieldValues := make([]*interface{}, fieldCount)
+	return dbc.ctx
+}
+
+func (dbc *DbConnection) debugPrint(queryStr string, args []interface{}) {
+	if !dbc.debug {
+		return
+	}
 
-	for fId := range fields {
-		fields[fId] = &fieldValues[fId]
+	if len(args) == 0 {
+		fmt.Printf("%v\n", queryStr)
+	} else {
+		fmt.Printf("%v %v\n", queryStr, args)
 	}
+}
 
-	err = row.Scan(fields...)
+func (dbc *DbConnection) exec(queryStr string, args []interface{}) (sql.Result, error) {
+	stmt, release, err := dbc.prepared(queryStr)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if t.recid.Exists {
-		offset = 1
-	} else {
-		offset = 0
+	if stmt == nil {
+		return dbc.execUnprepared(queryStr, args)
 	}
 
-	for fId := range fieldValues {
-		value := *fieldValues[fId]
-		strValue := fmt.Sprintf("%s", value)
-		if t.recid.Exists && fId == 0 {
-			t.recid.Value, _ = strconv.ParseUint(strValue, 10, 64)
-			t.recid.IsSet = false
-		} else {
-			t.fieldValue[fId-offset] = strValue
-			t.fieldValueSet[fId-offset] = false
-		}
-	}
+	defer release()
+	dbc.debugPrint(queryStr, args)
 
-	return nil
+	return stmt.ExecContext(dbc.Context(), args...)
 }
 
-// Builds and executes a select statement based on the field values that have been set using
-// using the SetFieldValue() function. Will return a slice of DbTable objects that represent
-// the selected table rows. If no lines are found, will return a 0 sized slice. Will return
-// nil value and an error if a problem was encountered.
-func (t DbTable) DoSelect(dbc *DbConnection) ([]DbTable, error) {
-	var retRows []DbTable
-	var counter int
-	var offset int
+func (dbc *DbConnection) execUnprepared(queryStr string, args []interface{}) (sql.Result, error) {
+	dbc.debugPrint(queryStr, args)
+
+	if dbc.tx != nil {
+		return dbc.tx.ExecContext(dbc.Context(), queryStr, args...)
+	}
+
+	return dbc.connection.ExecContext(dbc.Context(), queryStr, args...)
+}
 
-	queryStr, err := t.buildSelectStr(false, dbc.debug)
+func (dbc *DbConnection) query(queryStr string, args []interface{}) (*sql.Rows, error) {
+	stmt, release, err := dbc.prepared(queryStr)
 
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := dbc.connection.Query(queryStr)
+	dbc.debugPrint(queryStr, args)
 
-	if err != nil {
-		return nil, err
+	if stmt != nil {
+		defer release()
+		return stmt.QueryContext(dbc.Context(), args...)
 	}
 
-	for rows.Next() {
-		fieldCount := len(t.fieldNames)
+	if dbc.tx != nil {
+		return dbc.tx.QueryContext(dbc.Context(), queryStr, args...)
+	}
 
-		if t.recid.Exists {
-			fieldCount++
-		}
+	return dbc.connection.QueryContext(dbc.Context(), queryStr, args...)
+}
 
-		fields := make([]interface{}, fieldCount)
-		fieldValues := make([]*interface{}, fieldCount)
+func (dbc *DbConnection) queryRow(queryStr string, args []interface{}) *sql.Row {
+	stmt, release, err := dbc.prepared(queryStr)
 
-		for fId := range fields {
-			fields[fId] = &fieldValues[fId]
-		}
+	if err == nil && stmt != nil {
+		defer release()
+		dbc.debugPrint(queryStr, args)
+		return stmt.QueryRowContext(dbc.Context(), args...)
+	}
 
-		err = rows.Scan(fields...)
+	// without a statement the error of the prepare is returned by the query
+	dbc.debugPrint(queryStr, args)
 
-		if err != nil {
-			return nil, err
-		}
+	if dbc.tx != nil {
+		return dbc.tx.QueryRowContext(dbc.Context(), queryStr, args...)
+	}
 
-		tableRow := t.newTableInstance()
+	return dbc.connection.QueryRowContext(dbc.Context(), queryStr, args...)
+}
 
-		if t.recid.Exists {
-			offset = 1
-		} else {
-			offset = 0
-		}
-
-		for fId := range fieldValues {
-			value := *fieldValues[fId]
-			strValue := fmt.Sprintf("%s", value)
-			if t.recid.Exists && fId == 0 {
-				tableRow.recid.Value, _ = strconv.ParseUint(strValue, 10, 64)
-				tableRow.recid.IsSet = false
-			} else {
-				tableRow.fieldValue[fId-offset] = strValue
-				tableRow.fieldValueSet[fId-offset] = false
-			}
-		}
+// Executes a custom sql statement. Arguments are bound to the placeholders of the statement,
+// which depend on the dialect, e.g. ? for MySQL and $1 for PostgreSQL.
+func (dbc *DbConnection) Exec(queryStr string, args ...interface{}) (int64, error) {
+	result, err := dbc.execUnprepared(queryStr, args)
 
-		retRows = append(retRows, tableRow)
-		counter++
+	if err != nil {
+		return 0, err
 	}
 
-	rows.Close()
+	rowsAffected, _ := result.RowsAffected()
 
-	return retRows, nil
+	return rowsAffected, nil
 }
 
-func (t DbTable) buildInsertStr(debug bool) (string, error) {
-	var stmtStr string
-	var stmtFields string
-	var stmtValues string
-
-	stmtStr = "INSERT INTO " + t.tableName + " "
-
-	for fId := range t.fieldNames {
-		if t.fieldValueSet[fId] {
-			if len(stmtFields) == 0 {
-				stmtFields = stmtFields + "("
-				stmtValues = stmtValues + "("
-			} else {
-				stmtFields = stmtFields + ","
-				stmtValues = stmtValues + ","
-			}
+// Close the database connection
+func (dbc *DbConnection) Close() error {
+	if dbc.stmts != nil {
+		dbc.stmts.close()
+	}
 
-			stmtFields = stmtFields + t.fieldNames[fId]
-			stmtValues = stmtValues + toStmtStr(t.fieldValue[fId], t.fieldTypes[fId])
+	for _, replica := range dbc.replicas {
+		if replica.stmts != nil {
+			replica.stmts.close()
 		}
-	}
 
-	if t.recid.Exists && !t.recid.AutoInc {
-		stmtFields = stmtFields + "recid, "
-		stmtValues = stmtFields + toStmtStr(anytypeToStr(t.recid.Value), "BIGINT")
+		replica.db.Close()
 	}
 
-	if len(stmtFields) == 0 {
-		return "", fmt.Errorf("No fields set!")
-	} else {
-		stmtFields = stmtFields + ")"
-		stmtValues = stmtValues + ")"
+	return dbc.connection.Close()
+}
+
+// Returns the quoted field name qualified with the quoted table name
+func (t DbTable) quotedField(dialect Dialect, fieldName string) (string, error) {
+	tableStr, err := quoteIdent(dialect, t.tableName)
+
+	if err != nil {
+		return "", err
 	}
 
-	stmtStr = stmtStr + stmtFields + " VALUES " + stmtValues
+	fieldStr, err := quoteIdent(dialect, fieldName)
 
-	// for debugging
-	if debug {
-		fmt.Printf("%v\n", stmtStr)
+	if err != nil {
+		return "", err
 	}
 
-	return stmtStr, nil
+	return tableStr + "." + fieldStr, nil
 }
 
-// Builds and executes an insert statement from the set field values
-func (t *DbTable) DoInsert(dbc *DbConnection) error {
-	stmtStr, err := t.buildInsertStr(dbc.debug)
+func (t DbTable) buildSelectStr(dbc *DbConnection, where *Condition, firstonly bool) (string, []interface{}, error) {
+	args := stmtArgs{dialect: dbc.dialect}
+
+	tableStr, err := quoteIdent(dbc.dialect, t.tableName)
 
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
-	rows, err := dbc.Exec(stmtStr)
+	selectStr := "SELECT * FROM " + tableStr
 
-	if err != nil {
-		return err
-	}
+	where = t.scopeCondition(where)
 
-	if rows != 1 {
-		return fmt.Errorf("Something went wrong, insert affected %v rows.", rows)
+	if !where.IsEmpty() {
+		whereStr, err := where.buildStr(t, &args)
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		selectStr = selectStr + " WHERE " + whereStr
 	}
 
-	if t.recid.Exists && t.recid.AutoInc {
-		err = t.DoSelectFirstonly(dbc)
+	if firstonly {
+		selectStr = selectStr + " " + dbc.dialect.LimitStr(1, 0)
 	}
 
+	lockStr, err := t.buildLockStr(dbc)
+
 	if err != nil {
-		return err
+		return "", nil, err
 	}
 
-	return nil
-}
+	if len(lockStr) != 0 {
+		selectStr = selectStr + " " + lockStr
+	}
 
-func (t DbTable) buildDeleteStr(useRecId bool, debug bool) (string, error) {
-	var hasWhere bool
-	var whereStr string
+	return selectStr, args.args, nil
+}
 
-	deleteStr := "DELETE FROM " + t.tableName
+// Returns the scan destinations for a row of the table, recid first if it is used
+func (t DbTable) newScanValues() ([]interface{}, []interface{}) {
+	fieldCount := len(t.columns)
 
-	if useRecId {
-		whereStr = t.tableName + ".recid = " + toStmtStr(anytypeToStr(t.recid.Value), "BIGINT")
-		hasWhere = true
-	} else {
-		for fId, isSet := range t.fieldValueSet {
-			if isSet {
-				hasWhere = true
-				if len(whereStr) != 0 {
-					whereStr = whereStr + " AND "
-				}
-				whereStr = whereStr + t.tableName + "." + t.fieldNames[fId] + " = " + toStmtStr(t.fieldValue[fId], t.fieldTypes[fId])
+	if t.recid.Exists {
+		fieldCount++
+	}
 
-			}
-		}
+	values := make([]interface{}, fieldCount)
+	dest := make([]interface{}, fieldCount)
 
-		if t.recid.Exists && t.recid.IsSet {
-			if len(whereStr) != 0 {
-				whereStr = whereStr + " AND "
-			}
-			whereStr = whereStr + t.tableName + ".recid = " + toStmtStr(anytypeToStr(t.recid.Value), "BIGINT")
-		}
+	for fId := range values {
+		dest[fId] = &values[fId]
 	}
 
-	if !hasWhere {
-		return "", fmt.Errorf("Delete must have a where clause")
-	}
+	return values, dest
+}
 
-	deleteStr = deleteStr + " WHERE " + whereStr
+// Populates the fields from scanned values. The fields will be considered not set.
+func (t *DbTable) loadValues(values []interface{}) {
+	var offset int
 
-	// for debugging
-	if debug {
-		fmt.Printf("%v\n", deleteStr)
+	if t.recid.Exists {
+		offset = 1
 	}
 
-	return deleteStr, nil
-}
-
-// Deletes the selected record. If no record has previously been selected (recid has no value or it
-// has been set manually), an error will be returned. DoDelete will only work for tables that have
-// the recid field. To make sure a record has been selected, check if it has a recid.
-func (t *DbTable) DoDelete(dbcon *DbConnection) error {
-	if !t.recid.Exists || t.recid.IsSet || t.recid.Value == 0 {
-		return fmt.Errorf("No record has been selected, cant DoDelete()!")
+	for fId, value := range values {
+		strValue := valueToStr(value)
+		if t.recid.Exists && fId == 0 {
+			t.recid.Value, _ = strconv.ParseUint(strValue, 10, 64)
+			t.recid.IsSet = false
+		} else {
+			t.fieldValue[fId-offset] = strValue
+			t.fieldValueSet[fId-offset] = false
+			t.fieldExprs[fId-offset] = nil
+			t.fieldOrig[fId-offset] = strValue
+		}
 	}
 
-	deleteStr, err := t.buildDeleteStr(true, dbcon.debug)
+	t.loaded = true
+}
+
+func (t *DbTable) doSelectFirstonly(dbc *DbConnection, where *Condition) error {
+	queryStr, args, err := t.buildSelectStr(dbc, where, true)
 
 	if err != nil {
 		return err
 	}
 
-	rows, err := dbcon.Exec(deleteStr)
+	var valueRows [][]interface{}
+
+	err = dbc.retry(true, func() error {
+		valueRows, err = t.queryValues(dbc, queryStr, args)
+		return err
+	})
 
 	if err != nil {
 		return err
 	}
 
-	if rows != 1 {
-		return fmt.Errorf("Something went wrong, %i lines deleted. SQL query: %s", rows, deleteStr)
+	if len(valueRows) == 0 {
+		return sql.ErrNoRows
 	}
 
-	t.ClearFields()
+	t.loadValues(valueRows[0])
 
-	return nil
+	return t.runHooks(dbc, AfterSelect)
 }
 
-// Deletes all lines using the values that have been set in the fields. This will also include the recid
-// if it exists and has been set. This can be used for deleting records in bulk or deleting a specific
-// record by its recid without first selecting it. Will return the number of rows deleted or an error if
-// something went wrong. If no rows fit the criteria, 0 and no error will be returned.
-func (t *DbTable) DoDeleteWhere(dbcon *DbConnection) (int64, error) {
-	deleteStr, err := t.buildDeleteStr(false, dbcon.debug)
+// Builds and executes a select statement based on the field values that have been set using
+// using the SetFieldValue() function. Will return true if successfull and will populate the
+// field values for the variable called from. All fields returned by the db will be populated,
+// but fields will be considered not set. Selects only the first line from the table.
+func (t *DbTable) DoSelectFirstonly(dbc *DbConnection) error {
+	where, err := t.fieldCondition()
 
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	rows, err := dbcon.Exec(deleteStr)
+	return t.doSelectFirstonly(dbc, where)
+}
 
-	if err != nil {
-		return 0, err
+// Works like DoSelectFirstonly(), but selects the first line matching the where condition.
+// Field values set with SetFieldValue() are not used.
+func (t *DbTable) DoSelectFirstonlyWhere(dbc *DbConnection, where *Condition) error {
+	if where.IsEmpty() {
+		return fmt.Errorf("At least one condition must be specified in the where clause.")
 	}
 
-	return rows, nil
+	return t.doSelectFirstonly(dbc, where)
 }
 
-func (t DbTable) buildUpdateStr(useRecId bool, whereFields []DbUpdateField, debug bool) (string, error) {
-	var hasWhere bool
-	var hasSet bool
-	var whereStr string
-	var setStr string
+func (t DbTable) doSelect(dbc *DbConnection, where *Condition) ([]DbTable, error) {
+	var retRows []DbTable
 
-	if useRecId && (!t.recid.Exists || t.recid.Value == 0) {
-		return "", fmt.Errorf("Record has not been selected or the table does not use recid field.")
-	}
+	queryStr, args, err := t.buildSelectStr(dbc, where, false)
 
-	queryStr := "UPDATE " + t.tableName + " SET "
+	if err != nil {
+		return nil, err
+	}
 
-	for fId, isSet := range t.fieldValueSet {
-		if isSet {
-			if len(setStr) > 0 {
-				setStr = setStr + ", "
-			}
+	var valueRows [][]interface{}
 
-			setStr = setStr + "`" + t.fieldNames[fId] + "`" + " = " + toStmtStr(t.fieldValue[fId], t.fieldTypes[fId])
-			hasSet = true
-		}
-	}
+	err = dbc.retry(true, func() error {
+		valueRows, err = t.queryValues(dbc, queryStr, args)
+		return err
+	})
 
-	if !hasSet {
-		return "", fmt.Errorf("No fields have been set for update!")
+	if err != nil {
+		return nil, err
 	}
 
-	if useRecId {
-		whereStr = t.tableName + ".recid = " + anytypeToStr(t.recid.Value)
-		hasWhere = true
-	} else {
-		if whereFields == nil || len(whereFields) == 0 {
-			return "", fmt.Errorf("Missing where conditions for update.")
-		}
+	for _, values := range valueRows {
+		tableRow := t.newTableInstance()
+		tableRow.loadValues(values)
 
-		for _, field := range whereFields {
-			if len(whereStr) != 0 {
-				whereStr = whereStr + ", "
-			}
+		err = tableRow.runHooks(dbc, AfterSelect)
 
-			whereStr = whereStr + t.tableName + "." + field.FieldName + " = " + toStmtStr(field.Value, t.GetFieldType(field.FieldName))
-			hasWhere = true
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	if !hasWhere {
-		return "", fmt.Errorf("No condictions in the WHERE clause. recid is not used and condictions not passed in.")
+		retRows = append(retRows, tableRow)
 	}
 
-	queryStr = queryStr + setStr + " WHERE " + whereStr
+	return retRows, nil
+}
 
-	// for debugging
-	if debug {
-		fmt.Printf("%v\n", queryStr)
-	}
+// Builds and executes a select statement based on the field values that have been set using
+// using the SetFieldValue() function. Will return a slice of DbTable objects that represent
+// the selected table rows. If no lines are found, will return a 0 sized slice. Will return
+// nil value and an error if a problem was encountered.
+func (t DbTable) DoSelect(dbc *DbConnection) ([]DbTable, error) {
+	where, err := t.fieldCondition()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.doSelect(dbc, where)
+}
+
+// Works like DoSelect(), but selects the lines matching the where condition. Field values set
+// with SetFieldValue() are not used.
+func (t DbTable) DoSelectWhere(dbc *DbConnection, where *Condition) ([]DbTable, error) {
+	if where.IsEmpty() {
+		return nil, fmt.Errorf("At least one condition must be specified in the where clause.")
+	}
+
+	return t.doSelect(dbc, where)
+}
+
+// Returns the placeholder of the field value or the sql of the expression the field is set to
+func (t DbTable) fieldValueStr(fId int, fieldStr string, args *stmtArgs) (string, error) {
+	var valueStr string
+	var err error
+
+	if t.fieldExprs[fId] != nil {
+		valueStr, err = t.fieldExprs[fId].buildStr(fieldStr, t.columns[fId].Type, args)
+	} else {
+		valueStr, err = args.addValue(t.fieldValue[fId], t.columns[fId].Type)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("Field %s: %v", t.columns[fId].Name, err)
+	}
+
+	return valueStr, nil
+}
+
+func (t DbTable) buildInsertStr(dbc *DbConnection, returning bool) (string, []interface{}, error) {
+	var stmtFields []string
+	var stmtValues []string
+	args := stmtArgs{dialect: dbc.dialect}
+
+	tableStr, err := quoteIdent(dbc.dialect, t.tableName)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	recidStr, err := quoteIdent(dbc.dialect, "recid")
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	for fId := range t.columns {
+		if t.fieldValueSet[fId] {
+			fieldStr, err := quoteIdent(dbc.dialect, t.columns[fId].Name)
+
+			if err != nil {
+				return "", nil, err
+			}
+
+			if t.fieldExprs[fId] != nil && len(t.fieldExprs[fId].operator) != 0 {
+				return "", nil, fmt.Errorf("Field %s is incremented, increments can't be inserted", t.columns[fId].Name)
+			}
+
+			placeholder, err := t.fieldValueStr(fId, fieldStr, &args)
+
+			if err != nil {
+				return "", nil, err
+			}
+
+			stmtFields = append(stmtFields, fieldStr)
+			stmtValues = append(stmtValues, placeholder)
+		}
+	}
+
+	if t.recid.Exists && !t.recid.AutoInc {
+		stmtFields = append(stmtFields, recidStr)
+		stmtValues = append(stmtValues, args.add(t.recid.Value))
+	}
+
+	if len(stmtFields) == 0 {
+		return "", nil, fmt.Errorf("No fields set!")
+	}
+
+	stmtStr := "INSERT INTO " + tableStr + " (" + strings.Join(stmtFields, ",") + ") VALUES (" + strings.Join(stmtValues, ",") + ")"
+
+	if returning && t.recid.Exists && t.recid.AutoInc && dbc.dialect.InsertReturning() {
+		stmtStr = stmtStr + " RETURNING " + recidStr
+	}
+
+	return stmtStr, args.args, nil
+}
+
+// Builds and executes an insert statement from the set field values. If the table uses an
+// auto increment recid, the inserted row is selected back, populating recid and all the fields.
+func (t *DbTable) DoInsert(dbc *DbConnection) error {
+	err := t.runHooks(dbc, BeforeInsert)
+
+	if err != nil {
+		return err
+	}
+
+	err = dbc.auditTx(t, func(tx *DbConnection) error {
+		err := t.doInsert(tx)
+
+		if err != nil || !tx.audits(t) {
+			return err
+		}
+
+		return tx.writeAudit(t, "INSERT", t.recid.Value, nil, t.auditValues(t.loaded))
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return t.runHooks(dbc, AfterInsert)
+}
+
+func (t *DbTable) doInsert(dbc *DbConnection) error {
+	var recId uint64
+
+	if versionId := t.fieldId(t.versionField); versionId >= 0 && !t.fieldValueSet[versionId] {
+		version, err := t.initialVersion(dbc)
+
+		if err != nil {
+			return err
+		}
+
+		t.SetFieldValue(t.versionField, version)
+	}
+
+	_, err := t.setTimestamps(dbc, t.createdField, t.updatedField)
+
+	if err != nil {
+		return err
+	}
+
+	err = t.validate(true)
+
+	if err != nil {
+		return err
+	}
+
+	stmtStr, args, err := t.buildInsertStr(dbc, true)
+
+	if err != nil {
+		return err
+	}
+
+	if t.recid.Exists && t.recid.AutoInc && dbc.dialect.InsertReturning() {
+		err = dbc.queryRow(stmtStr, args).Scan(&recId)
+
+		if err != nil {
+			return err
+		}
+	} else {
+		result, err := dbc.exec(stmtStr, args)
+
+		if err != nil {
+			return err
+		}
+
+		rows, _ := result.RowsAffected()
+
+		if rows != 1 {
+			return fmt.Errorf("Something went wrong, insert affected %v rows.", rows)
+		}
+
+		if t.recid.Exists && t.recid.AutoInc {
+			lastId, err := result.LastInsertId()
+
+			if err != nil {
+				return err
+			}
+
+			recId = uint64(lastId)
+		}
+	}
+
+	dbc.tableWritten(t.tableName)
+
+	if t.recid.Exists && t.recid.AutoInc {
+		t.ClearFields()
+		t.SetRecId(recId)
+		return t.readBack(dbc)
+	}
+
+	return nil
+}
+
+// Reads the written row back from the primary, by its recid after an insert and by the conflict
+// fields after an upsert. The lock mode, the soft delete scope and the AfterSelect hooks are meant
+// for the selects of the application, the row is read as it has been written.
+func (t *DbTable) readBack(dbc *DbConnection) error {
+	where, err := t.fieldCondition()
+
+	if err != nil {
+		return err
+	}
+
+	tbl := *t
+	tbl.lockMode = LockNone
+	tbl.deletedScope = withDeleted
+
+	queryStr, args, err := tbl.buildSelectStr(dbc, where, true)
+
+	if err != nil {
+		return err
+	}
+
+	var valueRows [][]interface{}
+
+	err = dbc.retry(true, func() error {
+		valueRows, err = tbl.queryValues(dbc.Primary(), queryStr, args)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if len(valueRows) == 0 {
+		return sql.ErrNoRows
+	}
+
+	t.loadValues(valueRows[0])
+
+	return nil
+}
+
+func (t DbTable) buildUpsertStr(dbc *DbConnection, conflictFields []string) (string, []interface{}, error) {
+	var quotedConflict []string
+	var updateFields []string
+
+	for _, fieldName := range conflictFields {
+		fId := t.fieldId(fieldName)
+
+		if fId < 0 && (fieldName != "recid" || !t.recid.Exists) {
+			return "", nil, fmt.Errorf("Unknown conflict field %s", fieldName)
+		}
+
+		if fId >= 0 && !t.fieldValueSet[fId] {
+			return "", nil, fmt.Errorf("Conflict field %s must be set", fieldName)
+		}
+
+		fieldStr, err := quoteIdent(dbc.dialect, fieldName)
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		quotedConflict = append(quotedConflict, fieldStr)
+	}
+
+	versionStr, err := t.upsertVersionStr(dbc)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	for fId, isSet := range t.fieldValueSet {
+		fieldName := t.columns[fId].Name
+
+		if isSet && !inList(fieldName, conflictFields) && fieldName != t.createdField &&
+			(len(versionStr) == 0 || fieldName != t.versionField) {
+			fieldStr, err := quoteIdent(dbc.dialect, fieldName)
+
+			if err != nil {
+				return "", nil, err
+			}
+
+			updateFields = append(updateFields, fieldStr)
+		}
+	}
+
+	stmtStr, args, err := t.buildInsertStr(dbc, false)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	upsertStr, err := dbc.dialect.UpsertStr(quotedConflict, updateFields)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	// the update clauses of all dialects end with the list of assignments
+	if len(updateFields) != 0 && len(versionStr) != 0 {
+		upsertStr = upsertStr + ", " + versionStr
+	}
+
+	return stmtStr + " " + upsertStr, args, nil
+}
+
+// Inserts a row from the set field values or, if a row with the same values in the unique
+// conflictFields exists, updates that row with the other set field values. The conflict fields
+// must be set and covered by a unique index. If the table uses recid, the row is selected
+// back by the conflict fields, populating recid and all the fields.
+func (t *DbTable) DoUpsert(dbc *DbConnection, conflictFields ...string) error {
+	err := t.runHooks(dbc, BeforeInsert)
+
+	if err != nil {
+		return err
+	}
+
+	err = dbc.retry(t.idempotent(), func() error {
+		return dbc.auditTx(t, func(tx *DbConnection) error {
+			newValues := t.auditValues(false)
+			err := t.doUpsert(tx, conflictFields)
+
+			if err != nil || !tx.audits(t) {
+				return err
+			}
+
+			return tx.writeAudit(t, "UPSERT", t.recid.Value, nil, newValues)
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return t.runHooks(dbc, AfterInsert)
+}
+
+func (t *DbTable) doUpsert(dbc *DbConnection, conflictFields []string) error {
+	if versionId := t.fieldId(t.versionField); versionId >= 0 && !t.fieldValueSet[versionId] {
+		version, err := t.initialVersion(dbc)
+
+		if err != nil {
+			return err
+		}
+
+		t.SetFieldValue(t.versionField, version)
+	}
+
+	_, err := t.setTimestamps(dbc, t.createdField, t.updatedField)
+
+	if err != nil {
+		return err
+	}
+
+	err = t.validate(true)
+
+	if err != nil {
+		return err
+	}
+
+	stmtStr, args, err := t.buildUpsertStr(dbc, conflictFields)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.exec(stmtStr, args)
+
+	if err != nil {
+		return err
+	}
+
+	dbc.tableWritten(t.tableName)
+
+	if t.recid.Exists && len(conflictFields) != 0 {
+		for fId, fieldName := range t.GetFieldNameList() {
+			if !inList(fieldName, conflictFields) {
+				t.fieldValueSet[fId] = false
+			}
+		}
+
+		return t.readBack(dbc)
+	}
+
+	return nil
+}
+
+func inList(value string, list []string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t DbTable) buildDeleteStr(dbc *DbConnection, where *Condition) (string, []interface{}, error) {
+	args := stmtArgs{dialect: dbc.dialect}
+
+	if where.IsEmpty() {
+		return "", nil, fmt.Errorf("Delete must have a where clause")
+	}
+
+	tableStr, err := quoteIdent(dbc.dialect, t.tableName)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	whereStr, err := where.buildStr(t, &args)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	deleteStr := "DELETE FROM " + tableStr + " WHERE " + whereStr
+
+	return deleteStr, args.args, nil
+}
+
+// Deletes the selected record. If no record has previously been selected (recid has no value or it
+// has been set manually), an error will be returned. DoDelete will only work for tables that have
+// the recid field. To make sure a record has been selected, check if it has a recid.
+// Tables with a soft delete field are only marked as deleted, see SetSoftDeleteField().
+func (t *DbTable) DoDelete(dbcon *DbConnection) error {
+	return t.deleteRecord(dbcon, false)
+}
+
+// Deletes all lines using the values that have been set in the fields. This will also include the recid
+// if it exists and has been set. This can be used for deleting records in bulk or deleting a specific
+// record by its recid without first selecting it. Will return the number of rows deleted or an error if
+// something went wrong. If no rows fit the criteria, 0 and no error will be returned.
+// Tables with a soft delete field are only marked as deleted, see SetSoftDeleteField().
+func (t *DbTable) DoDeleteWhere(dbcon *DbConnection) (int64, error) {
+	return t.deleteWhere(dbcon, false)
+}
+
+func (t *DbTable) deleteRecord(dbcon *DbConnection, hard bool) error {
+	if !t.recid.Exists || t.recid.IsSet || t.recid.Value == 0 {
+		return fmt.Errorf("No record has been selected, cant DoDelete()!")
+	}
+
+	err := t.runHooks(dbcon, BeforeDelete)
+
+	if err != nil {
+		return err
+	}
+
+	err = dbcon.retry(true, func() error {
+		return dbcon.auditTx(t, func(tx *DbConnection) error {
+			rows, err := t.execDelete(tx, t.recIdCondition(), hard)
+
+			if err != nil {
+				return err
+			}
+
+			if rows != 1 {
+				return fmt.Errorf("Something went wrong, %v lines deleted.", rows)
+			}
+
+			if !tx.audits(t) {
+				return nil
+			}
+
+			return tx.writeAudit(t, "DELETE", t.recid.Value, t.auditValues(true), nil)
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = t.runHooks(dbcon, AfterDelete)
+	t.ClearFields()
+
+	return err
+}
+
+func (t *DbTable) deleteWhere(dbcon *DbConnection, hard bool) (int64, error) {
+	err := t.runHooks(dbcon, BeforeDelete)
+
+	if err != nil {
+		return 0, err
+	}
+
+	where, err := t.fieldCondition()
+
+	if err != nil {
+		return 0, err
+	}
+
+	var rows int64
+
+	err = dbcon.retry(true, func() error {
+		return dbcon.auditTx(t, func(tx *DbConnection) error {
+			auditRows, err := t.auditRows(tx, where, !hard && len(t.softDeleteField) != 0)
+
+			if err != nil {
+				return err
+			}
+
+			rows, err = t.execDelete(tx, where, hard)
+
+			if err != nil {
+				return err
+			}
+
+			for _, row := range auditRows {
+				err = tx.writeAudit(t, "DELETE", row.recid.Value, row.auditValues(true), nil)
+
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return rows, err
+	}
+
+	return rows, t.runHooks(dbcon, AfterDelete)
+}
+
+// Deletes the rows matching the condition or, for soft deleted tables, marks them as deleted
+func (t DbTable) execDelete(dbcon *DbConnection, where *Condition, hard bool) (int64, error) {
+	if !hard && len(t.softDeleteField) != 0 {
+		return t.softDelete(dbcon, where)
+	}
+
+	deleteStr, args, err := t.buildDeleteStr(dbcon, where)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return t.execWrite(dbcon, deleteStr, args)
+}
+
+// Builds the update of the set fields or, with dirtyOnly, of the changed fields
+func (t DbTable) buildUpdateStr(dbc *DbConnection, where *Condition, dirtyOnly bool) (string, []interface{}, error) {
+	var setStr string
+	args := stmtArgs{dialect: dbc.dialect}
+
+	tableStr, err := quoteIdent(dbc.dialect, t.tableName)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	queryStr := "UPDATE " + tableStr + " SET "
+
+	for fId, isSet := range t.fieldValueSet {
+		if isSet && (!dirtyOnly || t.isFieldDirty(fId)) {
+			if len(setStr) > 0 {
+				setStr = setStr + ", "
+			}
+
+			fieldStr, err := quoteIdent(dbc.dialect, t.columns[fId].Name)
+
+			if err != nil {
+				return "", nil, err
+			}
 
-	return queryStr, nil
+			valueStr, err := t.fieldValueStr(fId, fieldStr, &args)
+
+			if err != nil {
+				return "", nil, err
+			}
+
+			setStr = setStr + fieldStr + " = " + valueStr
+		}
+	}
+
+	if len(setStr) == 0 {
+		return "", nil, fmt.Errorf("No fields have been set for update!")
+	}
+
+	if where.IsEmpty() {
+		return "", nil, fmt.Errorf("Missing where conditions for update.")
+	}
+
+	whereStr, err := where.buildStr(t, &args)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	queryStr = queryStr + setStr + " WHERE " + whereStr
+
+	return queryStr, args.args, nil
 }
 
 // Updates the selected with the values set for fields. Cannot be used for tables that don't have
-// recid. A record must be selected before the DoUpdate can be called.
+// recid. A record must be selected before the DoUpdate can be called. Only the fields changed since
+// the record was selected are written, see IsDirty(). If no field has been changed, all set fields
+// are written unless SetSkipUnchangedUpdate() has been called.
 func (t *DbTable) DoUpdate(dbcon *DbConnection) error {
+	err := t.runHooks(dbcon, BeforeUpdate)
+
+	if err != nil {
+		return err
+	}
+
+	err = dbcon.retry(t.idempotent(), func() error {
+		return dbcon.auditTx(t, func(tx *DbConnection) error {
+			return t.doUpdate(tx)
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return t.runHooks(dbcon, AfterUpdate)
+}
+
+func (t *DbTable) doUpdate(dbcon *DbConnection) error {
 	if !t.recid.Exists {
 		return fmt.Errorf("This table does not have recid.")
 	}
@@ -673,55 +1304,207 @@ func (t *DbTable) DoUpdate(dbcon *DbConnection) error {
 		return fmt.Errorf("Record has not been selected")
 	}
 
-	queryStr, err := t.buildUpdateStr(true, nil, dbcon.debug)
+	dirtyOnly := t.IsDirty()
+
+	if !dirtyOnly && t.skipUnchanged {
+		return nil
+	}
+
+	err := t.validate(false)
+
+	if err != nil {
+		return err
+	}
+
+	where := t.recIdCondition()
+	versionId := t.fieldId(t.versionField)
+
+	if versionId >= 0 && t.fieldValueSet[versionId] {
+		return fmt.Errorf("Version field %s can't be set for update", t.versionField)
+	}
+
+	autoIds, err := t.setTimestamps(dbcon, t.updatedField)
 
 	if err != nil {
 		return err
 	}
 
-	rows, err := dbcon.Exec(queryStr)
+	if versionId >= 0 {
+		current, next, err := t.nextVersion(dbcon)
+
+		if err != nil {
+			t.unsetFields(autoIds)
+			return err
+		}
+
+		if len(current) == 0 {
+			where.And(t.versionField, "IS NULL")
+		} else {
+			where.And(t.versionField, "=", current)
+		}
+
+		t.fieldValue[versionId] = next
+		t.fieldValueSet[versionId] = true
+		autoIds = append(autoIds, versionId)
+	}
+
+	rows, err := t.execUpdate(dbcon, where, dirtyOnly)
 
 	if err != nil {
+		t.unsetFields(autoIds)
 		return err
 	}
 
+	if rows == 0 && versionId >= 0 {
+		t.unsetFields(autoIds)
+		return ErrStaleRecord
+	}
+
 	if rows != 1 {
 		return fmt.Errorf("Something went wrong, %v lines where updated.", rows)
 	}
 
+	if dbcon.audits(t) {
+		oldValues, newValues := t.auditChanges(dirtyOnly)
+		err = dbcon.writeAudit(t, "UPDATE", t.recid.Value, oldValues, newValues)
+
+		if err != nil {
+			t.unsetFields(autoIds)
+			return err
+		}
+	}
+
 	for fId, isSet := range t.fieldValueSet {
 		if isSet {
+			if t.fieldExprs[fId] == nil {
+				t.fieldOrig[fId] = t.fieldValue[fId]
+			}
 			t.fieldValueSet[fId] = false
+			t.fieldExprs[fId] = nil
 		}
 	}
 
 	return nil
 }
 
-// This table will update all records that meet the criteria specified by the whereFields slice.
+// Updates all records that meet the where condition, built with Where(), e.g.
+//
+//	rows, err := usersTable.DoUpdateWhere(&dbcon, dbop.Where("yr", "=", "2011").And("role", "<", "3"))
+//
 // Values to be updated must be set via the SetFieldValue() method. Updated row count will be returned.
-func (t *DbTable) DoUpdateWhere(dbcon *DbConnection, whereFields []DbUpdateField) (int64, error) {
-	if whereFields == nil {
-		return 0, fmt.Errorf("whereFields value can't be nil.")
+func (t *DbTable) DoUpdateWhere(dbcon *DbConnection, where *Condition) (int64, error) {
+	err := t.runHooks(dbcon, BeforeUpdate)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var rows int64
+
+	err = dbcon.retry(t.idempotent(), func() error {
+		return dbcon.auditTx(t, func(tx *DbConnection) error {
+			rows, err = t.doUpdateWhere(tx, where)
+			return err
+		})
+	})
+
+	if err != nil {
+		return rows, err
+	}
+
+	err = t.runHooks(dbcon, AfterUpdate)
+	t.ClearFields()
+
+	return rows, err
+}
+
+func (t *DbTable) doUpdateWhere(dbcon *DbConnection, where *Condition) (int64, error) {
+	if where.IsEmpty() {
+		return 0, fmt.Errorf("At least one condition must be specified in the where clause.")
 	}
 
-	if len(whereFields) == 0 {
-		return 0, fmt.Errorf("At least one field must be specified in the where clause.")
+	err := t.validate(false)
+
+	if err != nil {
+		return 0, err
+	}
+
+	versionId := t.fieldId(t.versionField)
+
+	if versionId >= 0 && t.fieldValueSet[versionId] {
+		return 0, fmt.Errorf("Version field %s can't be set for update", t.versionField)
+	}
+
+	autoIds, err := t.setTimestamps(dbcon, t.updatedField)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if versionId >= 0 {
+		err = t.setWhereVersion(dbcon, versionId)
+
+		if err != nil {
+			t.unsetFields(autoIds)
+			return 0, err
+		}
+
+		autoIds = append(autoIds, versionId)
 	}
 
-	queryStr, err := t.buildUpdateStr(false, whereFields, dbcon.debug)
+	auditRows, err := t.auditRows(dbcon, where, false)
 
 	if err != nil {
+		t.unsetFields(autoIds)
 		return 0, err
 	}
 
-	rows, err := dbcon.Exec(queryStr)
+	rows, err := t.execUpdate(dbcon, where, false)
 
 	if err != nil {
+		t.unsetFields(autoIds)
 		return rows, err
 	}
 
-	t.ClearFields()
+	newValues := t.auditValues(false)
+
+	for _, row := range auditRows {
+		oldValues := make(map[string]string)
+
+		for fieldName := range newValues {
+			oldValues[fieldName] = row.GetFieldValue(fieldName)
+		}
+
+		err = dbcon.writeAudit(t, "UPDATE", row.recid.Value, oldValues, newValues)
+
+		if err != nil {
+			return rows, err
+		}
+	}
+
+	return rows, nil
+}
+
+func (t DbTable) execUpdate(dbcon *DbConnection, where *Condition, dirtyOnly bool) (int64, error) {
+	queryStr, args, err := t.buildUpdateStr(dbcon, where, dirtyOnly)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return t.execWrite(dbcon, queryStr, args)
+}
+
+// Executes a write of the table and invalidates its cached selects
+func (t DbTable) execWrite(dbcon *DbConnection, stmtStr string, args []interface{}) (int64, error) {
+	result, err := dbcon.exec(stmtStr, args)
+
+	if err != nil {
+		return 0, err
+	}
+
+	dbcon.tableWritten(t.tableName)
+	rows, _ := result.RowsAffected()
 
 	return rows, nil
 }
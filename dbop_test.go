@@ -0,0 +1,101 @@
+package dbop
+
+import (
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Returns a connection to a new SQLite database in a temporary directory, closed when the test ends
+func openTestDB(t *testing.T) *DbConnection {
+	t.Helper()
+
+	var dbc DbConnection
+	err := dbc.OpenDriver("sqlite3", filepath.Join(t.TempDir(), "test.db"), false, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { dbc.Close() })
+
+	return &dbc
+}
+
+// Initiates and creates a table with an auto increment recid
+func createTestTable(t *testing.T, dbc *DbConnection, tbl *DbTable, schema TableSchema) {
+	t.Helper()
+
+	columns := append([]Column{{Name: "recid", Type: "BIGINT", AutoIncrement: true, PrimaryKey: true}}, schema.Columns...)
+	schema.Columns = columns
+
+	err := tbl.InitSchema(schema)
+
+	if err == nil {
+		err = tbl.DoCreateTable(dbc, false)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInsertUpdateDelete(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var users DbTable
+	createTestTable(t, dbc, &users, NewTableSchema("users",
+		Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45, NotNull: true}},
+		Column{Name: "rating", Type: "INT"},
+	))
+
+	users.SetFieldValue("name", "ann")
+	users.SetFieldValue("rating", "3")
+
+	if err := users.DoInsert(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	recId, _ := users.RecId()
+
+	if recId == 0 || users.GetFieldValue("name") != "ann" {
+		t.Fatalf("got recid %d, name %s after the insert", recId, users.GetFieldValue("name"))
+	}
+
+	users.SetFieldValue("name", "anna")
+	users.IncrementField("rating", "2")
+
+	if err := users.DoUpdate(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	selected := users.newTableInstance()
+	selected.SetRecId(recId)
+
+	if err := selected.DoSelectFirstonly(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if selected.GetFieldValue("name") != "anna" || selected.GetFieldValue("rating") != "5" {
+		t.Errorf("got %s, %s after the update", selected.GetFieldValue("name"), selected.GetFieldValue("rating"))
+	}
+
+	selected.ClearFields()
+	selected.SetFieldValue("rating", "1")
+	rows, err := selected.DoUpdateWhere(dbc, Where("name", "LIKE", "an%"))
+
+	if err != nil || rows != 1 {
+		t.Errorf("DoUpdateWhere updated %d rows, %v", rows, err)
+	}
+
+	if err = users.DoDelete(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := users.newTableInstance().DoSelect(dbc)
+
+	if err != nil || len(all) != 0 {
+		t.Errorf("got %d rows after the delete, %v", len(all), err)
+	}
+}
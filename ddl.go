@@ -0,0 +1,246 @@
+package dbop
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Describes the sql attributes of a field that are not needed for select, insert, update or delete,
+// but are needed to generate the CREATE TABLE statement. A zero value describes a nullable field
+// without a size or a default value.
+type FieldAttr struct {
+	Size       int    // length for character and binary types, precision for DECIMAL
+	Scale      int    // number of digits after the decimal point for DECIMAL
+	NotNull    bool   // true if the field is NOT NULL
+	Default    string // default value, quoted according to the field type. NULL and CURRENT_TIMESTAMP are used as is
+	HasDefault bool   // true if Default should be used, allows an empty string as the default value
+	Unsigned   bool   // true for UNSIGNED numeric fields
+}
+
+// Describes an index of the table used when generating the CREATE TABLE statement.
+// The recid primary key is added automatically and must not be described.
+type DbIndex struct {
+	Name   string
+	Unique bool
+	Fields []string
+}
+
+// Sets the DDL attributes of a field. Returns false if the field does not exist.
+func (t *DbTable) SetFieldAttr(fieldName string, attr FieldAttr) bool {
+	for fId, column := range t.columns {
+		if fieldName == column.Name {
+			t.columns[fId].FieldAttr = attr
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the DDL attributes of a field. The method will panic if the field does not exist.
+func (t DbTable) GetFieldAttr(fieldName string) FieldAttr {
+	return t.GetColumn(fieldName).FieldAttr
+}
+
+// Adds an index over one or more fields to the table definition.
+func (t *DbTable) AddIndex(name string, unique bool, fieldNames ...string) {
+	t.indexes = append(t.indexes, DbIndex{Name: name, Unique: unique, Fields: fieldNames})
+}
+
+// Returns the indexes added to the table definition
+func (t DbTable) GetIndexList() []DbIndex {
+	return t.indexes
+}
+
+func columnDefaultStr(dialect Dialect, fieldType string, attr FieldAttr) string {
+	switch strings.ToUpper(attr.Default) {
+	case "NULL", "CURRENT_TIMESTAMP", "CURRENT_TIMESTAMP()", "NOW()":
+		return attr.Default
+	}
+
+	return dialect.Literal(attr.Default, fieldType)
+}
+
+// Returns the name of an index created with CREATE INDEX. These names are unique within the
+// schema rather than the table, so the table name is prefixed unless the name already starts with it.
+func schemaIndexName(tableName string, indexName string) string {
+	if strings.HasPrefix(indexName, tableName+"_") {
+		return indexName
+	}
+
+	return tableName + "_" + indexName
+}
+
+// Builds the CREATE TABLE statement for the dialect from the table definition. The recid field,
+// if used, is created as the first column and the primary key of the table. Field sizes,
+// nullability and defaults are taken from the attributes set with SetFieldAttr(), indexes from
+// AddIndex(). Column comments are added where the dialect declares them in the column, see
+// Dialect.ColumnCommentStr(). For dialects that can't declare indexes inside CREATE TABLE, CREATE
+// INDEX statements follow, separated by semicolons, with the table name prefixed to the index names.
+func (t DbTable) CreateTableStr(dialect Dialect, ifNotExists bool) (string, error) {
+	var columns []string
+	var indexStmts []string
+
+	if len(t.tableName) == 0 {
+		return "", fmt.Errorf("Table has not been initiated")
+	}
+
+	tableStr, err := quoteIdent(dialect, t.tableName)
+
+	if err != nil {
+		return "", err
+	}
+
+	if t.recid.Exists {
+		recidStr, err := quoteIdent(dialect, "recid")
+
+		if err != nil {
+			return "", err
+		}
+
+		columns = append(columns, recidStr+" "+dialect.RecIdColumnStr(t.recid.AutoInc))
+	}
+
+	for _, column := range t.columns {
+		if !knownFieldType(column.Type) {
+			return "", fmt.Errorf("Field %s has an unknown type %s", column.Name, column.Type)
+		}
+
+		attr := column.FieldAttr
+		typeStr, err := dialect.ColumnTypeStr(column.Type, attr)
+
+		if err != nil {
+			return "", fmt.Errorf("Field %s: %v", column.Name, err)
+		}
+
+		fieldStr, err := quoteIdent(dialect, column.Name)
+
+		if err != nil {
+			return "", err
+		}
+
+		columnStr := fieldStr + " " + typeStr
+
+		if attr.NotNull {
+			columnStr = columnStr + " NOT NULL"
+		}
+
+		if attr.HasDefault {
+			columnStr = columnStr + " DEFAULT " + columnDefaultStr(dialect, column.Type, attr)
+		}
+
+		if len(column.Comment) != 0 {
+			if commentStr := dialect.ColumnCommentStr(column.Comment); len(commentStr) != 0 {
+				columnStr = columnStr + " " + commentStr
+			}
+		}
+
+		columns = append(columns, columnStr)
+	}
+
+	if len(columns) == 0 {
+		return "", fmt.Errorf("Table %s has no fields", t.tableName)
+	}
+
+	for _, index := range t.indexes {
+		if len(index.Fields) == 0 {
+			return "", fmt.Errorf("Index %s has no fields", index.Name)
+		}
+
+		fieldList := make([]string, len(index.Fields))
+		for i, fieldName := range index.Fields {
+			if t.fieldId(fieldName) < 0 && (fieldName != "recid" || !t.recid.Exists) {
+				return "", fmt.Errorf("Index %s uses an unknown field %s", index.Name, fieldName)
+			}
+			fieldList[i], err = quoteIdent(dialect, fieldName)
+
+			if err != nil {
+				return "", err
+			}
+		}
+
+		if dialect.InlineIndexes() {
+			indexNameStr, err := quoteIdent(dialect, index.Name)
+
+			if err != nil {
+				return "", err
+			}
+
+			indexStr := "KEY"
+			if index.Unique {
+				indexStr = "UNIQUE KEY"
+			}
+
+			columns = append(columns, indexStr+" "+indexNameStr+" ("+strings.Join(fieldList, ", ")+")")
+			continue
+		}
+
+		indexNameStr, err := quoteIdent(dialect, schemaIndexName(t.tableName, index.Name))
+
+		if err != nil {
+			return "", err
+		}
+
+		indexStr := "CREATE INDEX "
+		if index.Unique {
+			indexStr = "CREATE UNIQUE INDEX "
+		}
+		if ifNotExists {
+			indexStr = indexStr + "IF NOT EXISTS "
+		}
+
+		indexStmts = append(indexStmts, indexStr+indexNameStr+" ON "+tableStr+" ("+strings.Join(fieldList, ", ")+")")
+	}
+
+	createStr := "CREATE TABLE "
+	if ifNotExists {
+		createStr = createStr + "IF NOT EXISTS "
+	}
+
+	createStr = createStr + tableStr + " (\n  " + strings.Join(columns, ",\n  ") + "\n)"
+
+	for _, indexStmt := range indexStmts {
+		createStr = createStr + ";\n" + indexStmt
+	}
+
+	return createStr, nil
+}
+
+// Creates the table in the database using the statements built by CreateTableStr()
+func (t DbTable) DoCreateTable(dbc *DbConnection, ifNotExists bool) error {
+	createStr, err := t.CreateTableStr(dbc.dialect, ifNotExists)
+
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range splitStatements(dbc.dialect, createStr) {
+		_, err = dbc.Exec(stmt)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Drops the table from the database. Mostly useful for tests creating their tables with DoCreateTable().
+func (t DbTable) DoDropTable(dbc *DbConnection, ifExists bool) error {
+	tableStr, err := quoteIdent(dbc.dialect, t.tableName)
+
+	if err != nil {
+		return err
+	}
+
+	dropStr := "DROP TABLE "
+	if ifExists {
+		dropStr = dropStr + "IF EXISTS "
+	}
+
+	dropStr = dropStr + tableStr
+
+	_, err = dbc.Exec(dropStr)
+
+	return err
+}
@@ -0,0 +1,131 @@
+package dbop
+
+import (
+	"strings"
+	"testing"
+)
+
+// Returns a table using most of the column attributes and both kinds of indexes
+func testUsersTable(t *testing.T) DbTable {
+	t.Helper()
+
+	var tbl DbTable
+
+	schema := NewTableSchema("users",
+		Column{Name: "recid", Type: "BIGINT", AutoIncrement: true, PrimaryKey: true},
+		Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45, NotNull: true}},
+		Column{Name: "rating", Type: "DECIMAL", FieldAttr: FieldAttr{Size: 4, Scale: 2, Default: "1.5", HasDefault: true}},
+		Column{Name: "active", Type: "TINYINT", FieldAttr: FieldAttr{Unsigned: true, NotNull: true, Default: "1", HasDefault: true}},
+		Column{Name: "note", Type: "TEXT", Comment: "free text, isn't checked"},
+		Column{Name: "created", Type: "DATETIME", FieldAttr: FieldAttr{Default: "CURRENT_TIMESTAMP", HasDefault: true}},
+	)
+	schema.AddIndex("name_idx", true, "name")
+	schema.AddIndex("rating_idx", false, "rating", "active")
+
+	err := tbl.InitSchema(schema)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tbl
+}
+
+func TestCreateTableStr(t *testing.T) {
+	tbl := testUsersTable(t)
+
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{MySQL{}, "CREATE TABLE IF NOT EXISTS `users` (\n" +
+			"  `recid` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,\n" +
+			"  `name` VARCHAR(45) NOT NULL,\n" +
+			"  `rating` DECIMAL(4,2) DEFAULT 1.5,\n" +
+			"  `active` TINYINT UNSIGNED NOT NULL DEFAULT 1,\n" +
+			"  `note` TEXT COMMENT 'free text, isn''t checked',\n" +
+			"  `created` DATETIME DEFAULT CURRENT_TIMESTAMP,\n" +
+			"  UNIQUE KEY `name_idx` (`name`),\n" +
+			"  KEY `rating_idx` (`rating`, `active`)\n" +
+			")"},
+		{PostgreSQL{}, "CREATE TABLE IF NOT EXISTS \"users\" (\n" +
+			"  \"recid\" BIGSERIAL NOT NULL PRIMARY KEY,\n" +
+			"  \"name\" VARCHAR(45) NOT NULL,\n" +
+			"  \"rating\" NUMERIC(4,2) DEFAULT 1.5,\n" +
+			"  \"active\" SMALLINT NOT NULL DEFAULT 1,\n" +
+			"  \"note\" TEXT,\n" +
+			"  \"created\" TIMESTAMP DEFAULT CURRENT_TIMESTAMP\n" +
+			");\n" +
+			"CREATE UNIQUE INDEX IF NOT EXISTS \"users_name_idx\" ON \"users\" (\"name\");\n" +
+			"CREATE INDEX IF NOT EXISTS \"users_rating_idx\" ON \"users\" (\"rating\", \"active\")"},
+		{SQLite{}, "CREATE TABLE IF NOT EXISTS \"users\" (\n" +
+			"  \"recid\" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,\n" +
+			"  \"name\" VARCHAR(45) NOT NULL,\n" +
+			"  \"rating\" DECIMAL(4,2) DEFAULT 1.5,\n" +
+			"  \"active\" TINYINT NOT NULL DEFAULT 1,\n" +
+			"  \"note\" TEXT,\n" +
+			"  \"created\" DATETIME DEFAULT CURRENT_TIMESTAMP\n" +
+			");\n" +
+			"CREATE UNIQUE INDEX IF NOT EXISTS \"users_name_idx\" ON \"users\" (\"name\");\n" +
+			"CREATE INDEX IF NOT EXISTS \"users_rating_idx\" ON \"users\" (\"rating\", \"active\")"},
+	}
+
+	for _, test := range tests {
+		createStr, err := tbl.CreateTableStr(test.dialect, true)
+
+		if err != nil {
+			t.Errorf("%s: %v", test.dialect.Name(), err)
+			continue
+		}
+
+		if createStr != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.dialect.Name(), createStr, test.want)
+		}
+	}
+}
+
+func TestCreateTableStrErrors(t *testing.T) {
+	var empty, unknownType, unknownIndex DbTable
+
+	unknownType.InitTable("items", []string{"name"}, []string{"STRING"}, [2]bool{true, true})
+	unknownIndex.InitTable("items", []string{"name"}, []string{"VARCHAR"}, [2]bool{true, true})
+	unknownIndex.AddIndex("code_idx", false, "code")
+
+	for name, tbl := range map[string]DbTable{"not initiated": empty, "unknown type": unknownType, "unknown index field": unknownIndex} {
+		if _, err := tbl.CreateTableStr(MySQL{}, false); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestCreateTableIndexNames(t *testing.T) {
+	dbc := openTestDB(t)
+
+	// both tables use the index name name_idx, CREATE INDEX names must be unique in the schema
+	var users, roles DbTable
+	for _, tbl := range []struct {
+		tbl  *DbTable
+		name string
+	}{{&users, "users"}, {&roles, "roles"}} {
+		schema := NewTableSchema(tbl.name, Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}})
+		schema.AddIndex("name_idx", true, "name")
+		createTestTable(t, dbc, tbl.tbl, schema)
+	}
+
+	var audit DbTable
+	err := audit.InitSchema(AuditTableSchema("audit_log"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createStr, err := audit.CreateTableStr(SQLite{}, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(createStr, "CREATE INDEX \"audit_log_record_idx\"") {
+		t.Errorf("audit index prefixed twice:\n%s", createStr)
+	}
+}
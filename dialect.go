@@ -0,0 +1,219 @@
+package dbop
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Describes the sql differences between databases. All statements built by the package go
+// through the dialect of the connection, so the same DbTable definitions can be used with
+// any database that has a dialect.
+type Dialect interface {
+	// Returns the name of the dialect, e.g. mysql
+	Name() string
+
+	// Returns the bind parameter placeholder for the n-th argument of a statement, n starts at 1
+	Placeholder(n int) string
+
+	// Returns the identifier quoted for use in a statement
+	QuoteIdent(name string) string
+
+	// Returns the clause limiting the number of selected rows. An offset of 0 means no offset.
+	LimitStr(limit int, offset int) string
+
+	// Converts a field value of the given field type to a statement argument
+	BindValue(value string, fieldType string) (interface{}, error)
+
+	// Returns the field value as an sql literal of the given field type. Used for defaults in
+	// CREATE TABLE statements.
+	Literal(value string, fieldType string) string
+
+	// Returns true if the recid of an inserted row is read from a RETURNING clause,
+	// false if it is read from the LastInsertId() of the result
+	InsertReturning() bool
+
+	// Returns the statement setting the session time zone to the offset, e.g. +02:00.
+	// Returns an empty string if the database has no session time zone.
+	TimeZoneStr(offset string) string
+
+	// Returns the column type used in CREATE TABLE statements for a field type
+	ColumnTypeStr(fieldType string, attr FieldAttr) (string, error)
+
+	// Returns the column definition of the recid field used in CREATE TABLE statements,
+	// including its PRIMARY KEY constraint
+	RecIdColumnStr(autoInc bool) string
+
+	// Returns the clause appended to a column definition in CREATE TABLE statements setting the
+	// comment of the column. Returns an empty string if the comment is not part of the column
+	// definition, it is only kept in the table definition then.
+	ColumnCommentStr(comment string) string
+
+	// Returns true if indexes are declared inside the CREATE TABLE statement,
+	// false if they are created with separate CREATE INDEX statements
+	InlineIndexes() bool
+
+	// Returns the field type the columns query reports for a field type of a table definition
+	NormalizeType(fieldType string) string
+
+	// Returns the query listing the columns of a table, see DbConnection.TableColumns()
+	ColumnsQuery() string
+
+	// Returns the query listing the tables of the connected database
+	TablesQuery() string
+
+	// Returns the statements taking and releasing a named lock held by the session. The lock
+	// statement must return 1 if the lock was taken and 0 if another session holds it. Both take
+	// the lock name as the only argument. Empty strings mean the database has no named locks.
+	NamedLockStr() (lockStr string, unlockStr string)
+
+	// Returns the clause appended to an INSERT statement that updates the updateFields of the
+	// existing row when the row conflicts on the unique conflictFields. With no updateFields
+	// the conflicting row is left as it is. Field names are already quoted.
+	UpsertStr(conflictFields []string, updateFields []string) (string, error)
+
+	// Returns the clause appended to a SELECT statement taking the row lock of the lock mode.
+	// Returns an error if the database does not support the lock mode.
+	LockStr(mode LockMode) (string, error)
+
+	// Returns the comment and quoting rules used to split sql scripts into statements
+	ScriptSyntax() ScriptSyntax
+
+	// Returns true if schema changes like CREATE TABLE can be rolled back with a transaction
+	TransactionalDDL() bool
+}
+
+// Describes the comment and quoting rules of sql scripts that differ between databases.
+// Quotes with ', " and block comments are understood by all dialects.
+type ScriptSyntax struct {
+	HashComments     bool // # starts a comment up to the end of the line
+	BackslashEscapes bool // a backslash escapes the next character in quotes
+	Backticks        bool // identifiers can be quoted with `
+	DollarQuotes     bool // strings can be quoted with $$ or $tag$, E'' strings have backslash escapes
+	NestedComments   bool // block comments can be nested
+}
+
+// Returns the dialect used by default for a database/sql driver name
+func DialectForDriver(driverName string) Dialect {
+	switch driverName {
+	case "postgres", "pgx", "pq":
+		return PostgreSQL{}
+	case "sqlite3", "sqlite":
+		return SQLite{}
+	}
+
+	return MySQL{}
+}
+
+// Returns true if the field type can be used in table definitions
+func knownFieldType(fieldType string) bool {
+	switch fieldType {
+	case "BIT", "TINYINT", "BOOL", "BOOLEAN", "SMALLINT", "MEDIUMINT",
+		"INT", "INTEGER", "BIGINT", "SERIAL", "DECIMAL", "DEC", "FLOAT",
+		"DOUBLE", "YEAR", "DATE", "DATETIME", "TIMESTAMP", "TIME", "CHAR", "VARCHAR",
+		"BINARY", "VARBINARY", "TINYBLOB", "TINYTEXT", "BLOB", "TEXT",
+		"MEDIUMBLOB", "MEDIUMTEXT", "LONGBLOB", "LONGTEXT", "ENUM", "SET":
+		return true
+	}
+
+	return false
+}
+
+// Quotes an identifier with the dialect. This is the only way table, field and index names get
+// into statements. Names qualified with a schema, e.g. app.Users, have each part quoted separately.
+// Returns an error for names that can't be safely quoted: empty names or parts and names
+// containing quote or control characters.
+func quoteIdent(dialect Dialect, name string) (string, error) {
+	parts := strings.Split(name, ".")
+
+	for i, part := range parts {
+		if len(part) == 0 {
+			return "", fmt.Errorf("Invalid identifier %q, names can't be empty", name)
+		}
+
+		for _, r := range part {
+			if r < 0x20 || r == 0x7f || r == '`' || r == '"' || r == '\'' || r == '\\' {
+				return "", fmt.Errorf("Invalid identifier %q, names can't contain quotes or control characters", name)
+			}
+		}
+
+		parts[i] = dialect.QuoteIdent(part)
+	}
+
+	return strings.Join(parts, "."), nil
+}
+
+// Escapes the quote character inside a quoted string or identifier by doubling it
+func quoteWith(value string, quote string) string {
+	return quote + strings.Replace(value, quote, quote+quote, -1) + quote
+}
+
+// Builds the ON CONFLICT clause used for upserts by PostgreSQL and SQLite
+func onConflictStr(dialect Dialect, conflictFields []string, updateFields []string) (string, error) {
+	if len(conflictFields) == 0 {
+		return "", fmt.Errorf("%s upserts need the unique fields of the conflict", dialect.Name())
+	}
+
+	clauseStr := "ON CONFLICT (" + strings.Join(conflictFields, ", ") + ") DO "
+
+	if len(updateFields) == 0 {
+		return clauseStr + "NOTHING", nil
+	}
+
+	setList := make([]string, len(updateFields))
+	for i, field := range updateFields {
+		setList[i] = field + " = excluded." + field
+	}
+
+	return clauseStr + "UPDATE SET " + strings.Join(setList, ", "), nil
+}
+
+// Converts a scanned column value to the string representation used for field values.
+// NULL values become an empty string.
+func valueToStr(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return v.String()
+	}
+
+	return ""
+}
+
+// Builds the argument list of a statement and returns the placeholders of the dialect
+type stmtArgs struct {
+	dialect Dialect
+	args    []interface{}
+}
+
+// Adds an argument and returns its placeholder
+func (a *stmtArgs) add(value interface{}) string {
+	a.args = append(a.args, value)
+	return a.dialect.Placeholder(len(a.args))
+}
+
+// Adds a field value converted by the dialect and returns its placeholder
+func (a *stmtArgs) addValue(value string, fieldType string) (string, error) {
+	arg, err := a.dialect.BindValue(value, fieldType)
+
+	if err != nil {
+		return "", err
+	}
+
+	return a.add(arg), nil
+}
@@ -0,0 +1,178 @@
+package dbop
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MySQL dialect, used by default
+type MySQL struct{}
+
+func (MySQL) Name() string {
+	return "mysql"
+}
+
+func (MySQL) Placeholder(n int) string {
+	return "?"
+}
+
+func (MySQL) QuoteIdent(name string) string {
+	return quoteWith(name, "`")
+}
+
+func (MySQL) LimitStr(limit int, offset int) string {
+	if offset != 0 {
+		return "LIMIT " + strconv.Itoa(limit) + " OFFSET " + strconv.Itoa(offset)
+	}
+
+	return "LIMIT " + strconv.Itoa(limit)
+}
+
+func (MySQL) BindValue(value string, fieldType string) (interface{}, error) {
+	if !knownFieldType(fieldType) {
+		return nil, fmt.Errorf("Unknown field type %s", fieldType)
+	}
+
+	return value, nil
+}
+
+func (MySQL) Literal(value string, fieldType string) string {
+	switch fieldType {
+	case "BIT", "TINYINT", "BOOL", "BOOLEAN", "SMALLINT", "MEDIUMINT",
+		"INT", "INTEGER", "BIGINT", "SERIAL", "DECIMAL", "DEC", "FLOAT",
+		"DOUBLE", "YEAR":
+		return value
+
+	case "DATE", "DATETIME", "TIMESTAMP", "TIME", "CHAR", "VARCHAR",
+		"BINARY", "VARBINARY", "TINYBLOB", "TINYTEXT", "BLOB", "TEXT",
+		"MEDIUMBLOB", "MEDIUMTEXT", "LONGBLOB", "LONGTEXT", "ENUM", "SET":
+		return quoteWith(strings.Replace(value, "\\", "\\\\", -1), "'")
+	}
+
+	return ""
+}
+
+func (MySQL) InsertReturning() bool {
+	return false
+}
+
+func (MySQL) TimeZoneStr(offset string) string {
+	return "SET time_zone = " + quoteWith(offset, "'")
+}
+
+func (MySQL) ColumnTypeStr(fieldType string, attr FieldAttr) (string, error) {
+	typeStr := fieldType
+
+	switch fieldType {
+	case "CHAR", "VARCHAR", "BINARY", "VARBINARY":
+		if attr.Size <= 0 {
+			return "", fmt.Errorf("%s fields must have a size", fieldType)
+		}
+		typeStr = typeStr + "(" + strconv.Itoa(attr.Size) + ")"
+
+	case "DECIMAL", "DEC", "FLOAT", "DOUBLE":
+		if attr.Size > 0 {
+			typeStr = typeStr + "(" + strconv.Itoa(attr.Size) + "," + strconv.Itoa(attr.Scale) + ")"
+		}
+
+	case "BIT":
+		if attr.Size > 0 {
+			typeStr = typeStr + "(" + strconv.Itoa(attr.Size) + ")"
+		}
+	}
+
+	if attr.Unsigned {
+		switch fieldType {
+		case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "DECIMAL", "DEC", "FLOAT", "DOUBLE":
+			typeStr = typeStr + " UNSIGNED"
+		default:
+			return "", fmt.Errorf("%s fields can't be UNSIGNED", fieldType)
+		}
+	}
+
+	return typeStr, nil
+}
+
+func (MySQL) RecIdColumnStr(autoInc bool) string {
+	if autoInc {
+		return "BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY"
+	}
+
+	return "BIGINT UNSIGNED NOT NULL PRIMARY KEY"
+}
+
+func (d MySQL) ColumnCommentStr(comment string) string {
+	return "COMMENT " + d.Literal(comment, "VARCHAR")
+}
+
+func (MySQL) InlineIndexes() bool {
+	return true
+}
+
+func (MySQL) NormalizeType(fieldType string) string {
+	switch strings.ToUpper(fieldType) {
+	case "INTEGER":
+		return "INT"
+	case "DEC":
+		return "DECIMAL"
+	case "BOOL", "BOOLEAN":
+		return "TINYINT"
+	case "SERIAL":
+		return "BIGINT"
+	}
+
+	return strings.ToUpper(fieldType)
+}
+
+func (MySQL) ColumnsQuery() string {
+	return "SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, ORDINAL_POSITION, IS_NULLABLE, " +
+		"COLUMN_DEFAULT, COLUMN_KEY, EXTRA, CHARACTER_MAXIMUM_LENGTH, NUMERIC_PRECISION, NUMERIC_SCALE, COLUMN_COMMENT " +
+		"FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION"
+}
+
+func (MySQL) TablesQuery() string {
+	return "SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES " +
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME"
+}
+
+func (MySQL) NamedLockStr() (string, string) {
+	return "SELECT GET_LOCK(?, 0)", "SELECT RELEASE_LOCK(?)"
+}
+
+// MySQL takes the conflict from any unique index, conflictFields are only used to keep the row
+// as it is when there is nothing to update
+func (MySQL) UpsertStr(conflictFields []string, updateFields []string) (string, error) {
+	if len(updateFields) == 0 {
+		if len(conflictFields) == 0 {
+			return "", fmt.Errorf("mysql upserts without fields to update need a conflict field")
+		}
+		return "ON DUPLICATE KEY UPDATE " + conflictFields[0] + " = " + conflictFields[0], nil
+	}
+
+	setList := make([]string, len(updateFields))
+	for i, field := range updateFields {
+		setList[i] = field + " = VALUES(" + field + ")"
+	}
+
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(setList, ", "), nil
+}
+
+func (MySQL) ScriptSyntax() ScriptSyntax {
+	return ScriptSyntax{HashComments: true, BackslashEscapes: true, Backticks: true}
+}
+
+// MySQL commits implicitly before and after schema changes
+func (MySQL) TransactionalDDL() bool {
+	return false
+}
+
+// FOR SHARE, NOWAIT and SKIP LOCKED need MySQL 8.0, shared locks without them use the older
+// LOCK IN SHARE MODE
+func (MySQL) LockStr(mode LockMode) (string, error) {
+	if mode == LockForShare {
+		return "LOCK IN SHARE MODE", nil
+	}
+
+	return mode.String(), nil
+}
@@ -0,0 +1,209 @@
+package dbop
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PostgreSQL dialect. Used by default for the postgres and pgx drivers. The recid of inserted
+// rows is read with RETURNING, auto increment recid fields are created as BIGSERIAL.
+type PostgreSQL struct{}
+
+func (PostgreSQL) Name() string {
+	return "postgres"
+}
+
+func (PostgreSQL) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (PostgreSQL) QuoteIdent(name string) string {
+	return quoteWith(name, "\"")
+}
+
+func (PostgreSQL) LimitStr(limit int, offset int) string {
+	if offset != 0 {
+		return "LIMIT " + strconv.Itoa(limit) + " OFFSET " + strconv.Itoa(offset)
+	}
+
+	return "LIMIT " + strconv.Itoa(limit)
+}
+
+func (PostgreSQL) BindValue(value string, fieldType string) (interface{}, error) {
+	switch fieldType {
+	case "BOOL", "BOOLEAN":
+		switch strings.ToLower(value) {
+		case "1", "t", "true", "y", "yes", "on":
+			return true, nil
+		case "0", "f", "false", "n", "no", "off":
+			return false, nil
+		}
+		return nil, fmt.Errorf("Invalid %s value %s", fieldType, value)
+	}
+
+	if !knownFieldType(fieldType) {
+		return nil, fmt.Errorf("Unknown field type %s", fieldType)
+	}
+
+	return value, nil
+}
+
+func (PostgreSQL) Literal(value string, fieldType string) string {
+	switch fieldType {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "SERIAL",
+		"DECIMAL", "DEC", "FLOAT", "DOUBLE", "YEAR":
+		return value
+
+	case "BOOL", "BOOLEAN":
+		switch strings.ToLower(value) {
+		case "1", "t", "true", "y", "yes", "on":
+			return "TRUE"
+		}
+		return "FALSE"
+
+	case "BIT":
+		return "B" + quoteWith(value, "'")
+
+	case "DATE", "DATETIME", "TIMESTAMP", "TIME", "CHAR", "VARCHAR",
+		"BINARY", "VARBINARY", "TINYBLOB", "TINYTEXT", "BLOB", "TEXT",
+		"MEDIUMBLOB", "MEDIUMTEXT", "LONGBLOB", "LONGTEXT", "ENUM", "SET":
+		return quoteWith(value, "'")
+	}
+
+	return ""
+}
+
+func (PostgreSQL) InsertReturning() bool {
+	return true
+}
+
+func (PostgreSQL) TimeZoneStr(offset string) string {
+	return "SET TIME ZONE INTERVAL " + quoteWith(offset, "'") + " HOUR TO MINUTE"
+}
+
+func (PostgreSQL) ColumnTypeStr(fieldType string, attr FieldAttr) (string, error) {
+	switch fieldType {
+	case "TINYINT", "SMALLINT", "YEAR":
+		return "SMALLINT", nil
+	case "MEDIUMINT", "INT", "INTEGER":
+		return "INTEGER", nil
+	case "BIGINT", "SERIAL":
+		return "BIGINT", nil
+	case "BOOL", "BOOLEAN":
+		return "BOOLEAN", nil
+	case "FLOAT":
+		return "REAL", nil
+	case "DOUBLE":
+		return "DOUBLE PRECISION", nil
+	case "DATE", "TIME":
+		return fieldType, nil
+	case "DATETIME", "TIMESTAMP":
+		return "TIMESTAMP", nil
+	case "TINYTEXT", "TEXT", "MEDIUMTEXT", "LONGTEXT", "ENUM", "SET":
+		return "TEXT", nil
+	case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB":
+		return "BYTEA", nil
+
+	case "DECIMAL", "DEC":
+		if attr.Size > 0 {
+			return "NUMERIC(" + strconv.Itoa(attr.Size) + "," + strconv.Itoa(attr.Scale) + ")", nil
+		}
+		return "NUMERIC", nil
+
+	case "CHAR", "VARCHAR":
+		if attr.Size <= 0 {
+			return "", fmt.Errorf("%s fields must have a size", fieldType)
+		}
+		return fieldType + "(" + strconv.Itoa(attr.Size) + ")", nil
+
+	case "BIT":
+		if attr.Size > 0 {
+			return "BIT(" + strconv.Itoa(attr.Size) + ")", nil
+		}
+		return "BIT", nil
+	}
+
+	return "", fmt.Errorf("Unknown field type %s", fieldType)
+}
+
+func (PostgreSQL) RecIdColumnStr(autoInc bool) string {
+	if autoInc {
+		return "BIGSERIAL NOT NULL PRIMARY KEY"
+	}
+
+	return "BIGINT NOT NULL PRIMARY KEY"
+}
+
+// PostgreSQL sets comments with separate COMMENT ON COLUMN statements
+func (PostgreSQL) ColumnCommentStr(comment string) string {
+	return ""
+}
+
+func (PostgreSQL) InlineIndexes() bool {
+	return false
+}
+
+func (PostgreSQL) NormalizeType(fieldType string) string {
+	switch strings.ToUpper(fieldType) {
+	case "TINYINT", "YEAR":
+		return "SMALLINT"
+	case "MEDIUMINT", "INTEGER":
+		return "INT"
+	case "SERIAL":
+		return "BIGINT"
+	case "BOOL":
+		return "BOOLEAN"
+	case "DEC":
+		return "DECIMAL"
+	case "TIMESTAMP":
+		return "DATETIME"
+	case "TINYTEXT", "MEDIUMTEXT", "LONGTEXT", "ENUM", "SET":
+		return "TEXT"
+	case "BINARY", "VARBINARY", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB":
+		return "BLOB"
+	}
+
+	return strings.ToUpper(fieldType)
+}
+
+// Reports the postgres types with the field type names of the table definitions, so
+// NormalizeType() can be compared with the reported type
+func (PostgreSQL) ColumnsQuery() string {
+	return "SELECT column_name, " +
+		"CASE udt_name WHEN 'int2' THEN 'SMALLINT' WHEN 'int4' THEN 'INT' WHEN 'int8' THEN 'BIGINT' " +
+		"WHEN 'bool' THEN 'BOOLEAN' WHEN 'float4' THEN 'FLOAT' WHEN 'float8' THEN 'DOUBLE' " +
+		"WHEN 'numeric' THEN 'DECIMAL' WHEN 'bpchar' THEN 'CHAR' WHEN 'varchar' THEN 'VARCHAR' " +
+		"WHEN 'timestamp' THEN 'DATETIME' WHEN 'timestamptz' THEN 'DATETIME' WHEN 'bytea' THEN 'BLOB' " +
+		"ELSE UPPER(udt_name) END, " +
+		"data_type, ordinal_position, is_nullable, column_default, '', " +
+		"CASE WHEN column_default LIKE 'nextval(%' THEN 'auto_increment' ELSE '' END, " +
+		"character_maximum_length, numeric_precision, numeric_scale, '' " +
+		"FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 ORDER BY ordinal_position"
+}
+
+func (PostgreSQL) TablesQuery() string {
+	return "SELECT table_name FROM information_schema.tables " +
+		"WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' ORDER BY table_name"
+}
+
+func (PostgreSQL) NamedLockStr() (string, string) {
+	return "SELECT CASE WHEN pg_try_advisory_lock(hashtext($1)) THEN 1 ELSE 0 END",
+		"SELECT pg_advisory_unlock(hashtext($1))"
+}
+
+func (d PostgreSQL) UpsertStr(conflictFields []string, updateFields []string) (string, error) {
+	return onConflictStr(d, conflictFields, updateFields)
+}
+
+func (PostgreSQL) LockStr(mode LockMode) (string, error) {
+	return mode.String(), nil
+}
+
+func (PostgreSQL) ScriptSyntax() ScriptSyntax {
+	return ScriptSyntax{DollarQuotes: true, NestedComments: true}
+}
+
+func (PostgreSQL) TransactionalDDL() bool {
+	return true
+}
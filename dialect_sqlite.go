@@ -0,0 +1,154 @@
+package dbop
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SQLite dialect, used by default for the sqlite3 and sqlite drivers. Works with on-disk and
+// in-memory databases. Field types are kept as declared, SQLite maps them to its type affinities.
+// SQLite has no session time zone and no named locks.
+type SQLite struct{}
+
+func (SQLite) Name() string {
+	return "sqlite"
+}
+
+func (SQLite) Placeholder(n int) string {
+	return "?"
+}
+
+func (SQLite) QuoteIdent(name string) string {
+	return quoteWith(name, "\"")
+}
+
+func (SQLite) LimitStr(limit int, offset int) string {
+	if offset != 0 {
+		return "LIMIT " + strconv.Itoa(limit) + " OFFSET " + strconv.Itoa(offset)
+	}
+
+	return "LIMIT " + strconv.Itoa(limit)
+}
+
+func (SQLite) BindValue(value string, fieldType string) (interface{}, error) {
+	switch fieldType {
+	case "BOOL", "BOOLEAN":
+		switch strings.ToLower(value) {
+		case "1", "t", "true", "y", "yes", "on":
+			return int64(1), nil
+		case "0", "f", "false", "n", "no", "off":
+			return int64(0), nil
+		}
+		return nil, fmt.Errorf("Invalid %s value %s", fieldType, value)
+	}
+
+	if !knownFieldType(fieldType) {
+		return nil, fmt.Errorf("Unknown field type %s", fieldType)
+	}
+
+	return value, nil
+}
+
+func (SQLite) Literal(value string, fieldType string) string {
+	switch fieldType {
+	case "BIT", "TINYINT", "BOOL", "BOOLEAN", "SMALLINT", "MEDIUMINT",
+		"INT", "INTEGER", "BIGINT", "SERIAL", "DECIMAL", "DEC", "FLOAT",
+		"DOUBLE", "YEAR":
+		return value
+	}
+
+	return quoteWith(value, "'")
+}
+
+func (SQLite) InsertReturning() bool {
+	return false
+}
+
+func (SQLite) TimeZoneStr(offset string) string {
+	return ""
+}
+
+func (SQLite) ColumnTypeStr(fieldType string, attr FieldAttr) (string, error) {
+	switch fieldType {
+	case "CHAR", "VARCHAR", "BINARY", "VARBINARY":
+		if attr.Size <= 0 {
+			return "", fmt.Errorf("%s fields must have a size", fieldType)
+		}
+		return fieldType + "(" + strconv.Itoa(attr.Size) + ")", nil
+
+	case "DECIMAL", "DEC":
+		if attr.Size > 0 {
+			return fieldType + "(" + strconv.Itoa(attr.Size) + "," + strconv.Itoa(attr.Scale) + ")", nil
+		}
+	}
+
+	if !knownFieldType(fieldType) {
+		return "", fmt.Errorf("Unknown field type %s", fieldType)
+	}
+
+	return fieldType, nil
+}
+
+// The recid must be declared as INTEGER PRIMARY KEY to be an alias of the rowid
+func (SQLite) RecIdColumnStr(autoInc bool) string {
+	if autoInc {
+		return "INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT"
+	}
+
+	return "INTEGER NOT NULL PRIMARY KEY"
+}
+
+// SQLite has no column comments
+func (SQLite) ColumnCommentStr(comment string) string {
+	return ""
+}
+
+func (SQLite) InlineIndexes() bool {
+	return false
+}
+
+func (SQLite) NormalizeType(fieldType string) string {
+	return strings.ToUpper(fieldType)
+}
+
+// Reports the declared types without their size. A recid is reported as auto_increment if it
+// is an INTEGER PRIMARY KEY and the table was created with the AUTOINCREMENT keyword.
+func (SQLite) ColumnsQuery() string {
+	return "SELECT name, " +
+		"CASE WHEN instr(type, '(') > 0 THEN UPPER(trim(substr(type, 1, instr(type, '(') - 1))) ELSE UPPER(type) END, " +
+		"type, cid + 1, CASE WHEN \"notnull\" THEN 'NO' ELSE 'YES' END, dflt_value, " +
+		"CASE WHEN pk THEN 'PRI' ELSE '' END, " +
+		"CASE WHEN pk = 1 AND UPPER(type) = 'INTEGER' AND EXISTS (SELECT 1 FROM sqlite_master m " +
+		"WHERE m.type = 'table' AND m.name = p.arg AND UPPER(m.sql) LIKE '%AUTOINCREMENT%') THEN 'auto_increment' ELSE '' END, " +
+		"CASE WHEN instr(type, '(') > 0 THEN CAST(substr(type, instr(type, '(') + 1) AS INTEGER) END, " +
+		"CASE WHEN instr(type, '(') > 0 THEN CAST(substr(type, instr(type, '(') + 1) AS INTEGER) END, " +
+		"CASE WHEN instr(type, ',') > 0 THEN CAST(substr(type, instr(type, ',') + 1) AS INTEGER) END, '' " +
+		"FROM pragma_table_info(?) p ORDER BY cid"
+}
+
+func (SQLite) TablesQuery() string {
+	return "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name"
+}
+
+func (SQLite) NamedLockStr() (string, string) {
+	return "", ""
+}
+
+func (d SQLite) UpsertStr(conflictFields []string, updateFields []string) (string, error) {
+	return onConflictStr(d, conflictFields, updateFields)
+}
+
+// SQLite locks the whole database instead of rows, a write transaction has to be used instead
+func (SQLite) LockStr(mode LockMode) (string, error) {
+	return "", fmt.Errorf("sqlite has no row locks, lock mode %s can't be used", mode)
+}
+
+// SQLite also accepts MySQL style backticks for identifiers
+func (SQLite) ScriptSyntax() ScriptSyntax {
+	return ScriptSyntax{Backticks: true}
+}
+
+func (SQLite) TransactionalDDL() bool {
+	return true
+}
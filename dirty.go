@@ -0,0 +1,57 @@
+package dbop
+
+// Returns true if a field of the selected record has been changed. A field is changed if it has
+// been set to a different value than the one loaded from the db or to an expression. If no
+// record has been selected, every set field counts as changed.
+func (t DbTable) IsDirty() bool {
+	for fId := range t.columns {
+		if t.isFieldDirty(fId) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the names of the changed fields, see IsDirty()
+func (t DbTable) DirtyFields() []string {
+	var fieldNames []string
+
+	for fId, fieldName := range t.GetFieldNameList() {
+		if t.isFieldDirty(fId) {
+			fieldNames = append(fieldNames, fieldName)
+		}
+	}
+
+	return fieldNames
+}
+
+// Returns the value of a field as it was loaded from the db when the record was selected.
+// Returns an empty string if no record has been selected or the field does not exist.
+func (t DbTable) OriginalValue(fieldName string) string {
+	fId := t.fieldId(fieldName)
+
+	if fId < 0 || !t.loaded {
+		return ""
+	}
+
+	return t.fieldOrig[fId]
+}
+
+// Sets DoUpdate() to return without executing a statement when no field has been changed.
+// By default all set fields are written when none of them has been changed.
+func (t *DbTable) SetSkipUnchangedUpdate(skip bool) {
+	t.skipUnchanged = skip
+}
+
+func (t DbTable) isFieldDirty(fId int) bool {
+	if !t.fieldValueSet[fId] {
+		return false
+	}
+
+	if !t.loaded || t.fieldExprs[fId] != nil {
+		return true
+	}
+
+	return t.fieldValue[fId] != t.fieldOrig[fId]
+}
@@ -0,0 +1,131 @@
+package dbop
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDirtyFields(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var users DbTable
+	createTestTable(t, dbc, &users, NewTableSchema("users",
+		Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}},
+		Column{Name: "rating", Type: "INT"},
+	))
+
+	users.SetFieldValue("name", "ann")
+
+	if !users.IsDirty() || !reflect.DeepEqual(users.DirtyFields(), []string{"name"}) {
+		t.Errorf("before a select, got dirty %v, fields %v", users.IsDirty(), users.DirtyFields())
+	}
+
+	users.SetFieldValue("rating", "3")
+
+	if err := users.DoInsert(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if users.IsDirty() || users.OriginalValue("name") != "ann" {
+		t.Errorf("after the insert, got dirty %v, original name %q", users.IsDirty(), users.OriginalValue("name"))
+	}
+
+	users.SetFieldValue("name", "ann")
+
+	if users.IsDirty() {
+		t.Errorf("a field set to its loaded value counts as changed")
+	}
+
+	users.SetFieldValue("rating", "4")
+
+	if !reflect.DeepEqual(users.DirtyFields(), []string{"rating"}) {
+		t.Errorf("got changed fields %v, want [rating]", users.DirtyFields())
+	}
+
+	users.ClearFields()
+
+	if users.IsDirty() || users.OriginalValue("name") != "" {
+		t.Errorf("after ClearFields, got dirty %v, original name %q", users.IsDirty(), users.OriginalValue("name"))
+	}
+}
+
+func TestUpdateChangedFields(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var users DbTable
+	createTestTable(t, dbc, &users, NewTableSchema("users",
+		Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}},
+		Column{Name: "rating", Type: "INT"},
+	))
+
+	users.SetFieldValue("name", "ann")
+	users.SetFieldValue("rating", "3")
+
+	if err := users.DoInsert(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	recId, _ := users.RecId()
+
+	// another instance changes the row after users has loaded it
+	other := users.newTableInstance()
+	setRow := func(name string, rating string) {
+		t.Helper()
+		other.ClearFields()
+		other.SetFieldValue("name", name)
+		other.SetFieldValue("rating", rating)
+
+		if _, err := other.DoUpdateWhere(dbc, Where("recid", "=", strconv.FormatUint(recId, 10))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	row := func() (string, string) {
+		t.Helper()
+		selected := users.newTableInstance()
+		selected.SetRecId(recId)
+
+		if err := selected.DoSelectFirstonly(dbc); err != nil {
+			t.Fatal(err)
+		}
+
+		return selected.GetFieldValue("name"), selected.GetFieldValue("rating")
+	}
+
+	setRow("ann", "9")
+	users.SetFieldValue("name", "bob")
+	users.SetFieldValue("rating", "3")
+
+	if err := users.DoUpdate(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, rating := row(); name != "bob" || rating != "9" {
+		t.Errorf("got %s, %s, only the changed name should be written", name, rating)
+	}
+
+	// nothing changed, all set fields are written
+	setRow("carl", "9")
+	users.SetFieldValue("name", "bob")
+
+	if err := users.DoUpdate(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, _ := row(); name != "bob" {
+		t.Errorf("got name %s, an unchanged update should write the set fields", name)
+	}
+
+	// with SetSkipUnchangedUpdate the update is not executed
+	setRow("carl", "9")
+	users.SetSkipUnchangedUpdate(true)
+	users.SetFieldValue("name", "bob")
+
+	if err := users.DoUpdate(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, _ := row(); name != "carl" {
+		t.Errorf("got name %s, the unchanged update should be skipped", name)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"github.com/mcomsis/dbop"
+	_ "github.com/ziutek/mymysql/godrv" // dbop works with any database/sql driver, Open() uses mymysql
 )
 
 const dbString = "test/root/"
@@ -52,6 +53,13 @@ func newUsersTable() dbop.DbTable {
 
 	// InitTable() called with the value prepared
 	usersTable.InitTable("Users", fieldNames, fieldTypes, recid)
+
+	// optional DDL attributes and indexes, only needed for creating the table with DoCreateTable()
+	usersTable.SetFieldAttr("name", dbop.FieldAttr{Size: 45, NotNull: true})
+	usersTable.SetFieldAttr("registered", dbop.FieldAttr{NotNull: true})
+	usersTable.SetFieldAttr("rating", dbop.FieldAttr{Size: 10, Scale: 2})
+	usersTable.AddIndex("name_UNIQUE", true, "name")
+
 	return usersTable
 }
 
@@ -61,6 +69,13 @@ func main() {
 
 	usersTable := newUsersTable()
 
+	// the table can be created from the same definition. CreateTableStr() returns the statement instead
+	err := usersTable.DoCreateTable(&dbcon, true)
+
+	if err != nil {
+		fmt.Printf("err = %v\n", err)
+	}
+
 	// Insert is very simple. set all the field values and call DoInsert() 
 	fmt.Printf("===== DoInsert()\n")
 	usersTable.SetFieldValue("name", "testing 1 one")
@@ -68,7 +83,7 @@ func main() {
 	usersTable.SetFieldValue("role", "1")
 	usersTable.SetFieldValue("rating", "1.7")
 	usersTable.SetFieldValue("yr", "2011")
-	err := usersTable.DoInsert(&dbcon) // return error if something went wrong.
+	err = usersTable.DoInsert(&dbcon) // return error if something went wrong.
 
 	if err != nil {
 		fmt.Printf("err = %v\n", err)
@@ -164,18 +179,11 @@ func main() {
 
 	usersTable.ClearFields()
 
-	// now we can do a bulk update. bulk update is a little clumsy, suggestions welcome
+	// now we can do a bulk update of all users matching the where condition
 	fmt.Printf("===== DoUpdate()\n")
 	usersTable.SetFieldValue("rating", "3.3")
 
-	// we have to manually prepare the WHERE list
-	var whereField dbop.DbUpdateField
-	whereFields := make([]dbop.DbUpdateField, 1)
-	whereField.FieldName = "yr"
-	whereField.Value = "2011"
-	whereFields[0] = whereField
-
-	lines, err := usersTable.DoUpdateWhere(&dbcon, whereFields)
+	lines, err := usersTable.DoUpdateWhere(&dbcon, dbop.Where("yr", "=", "2011"))
 
 	if err != nil {
 		fmt.Printf("error = %v\n", err)
@@ -0,0 +1,88 @@
+package dbop
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A raw sql expression a field is set to, used instead of the field value by inserts and updates
+type fieldExpr struct {
+	expr     string        // ? are the placeholders of args
+	args     []interface{} // bound as they are, without conversion by the dialect
+	operator string        // + or - for increments and decrements, the expression is then the field itself
+	value    string        // the increment, bound as a value of the field type
+}
+
+// Sets a field to a sql expression that is evaluated by the database, e.g.
+//
+//	usersTable.SetFieldExpr("rating", "rating + ?", 0.5)
+//	usersTable.SetFieldExpr("registered", "CURRENT_TIMESTAMP")
+//
+// Every ? in the expression is a placeholder for the next argument and is replaced with the
+// placeholder of the dialect. The expression is used in the VALUES of DoInsert() and the SET of
+// DoUpdate() and DoUpdateWhere(). Field names in the expression are not quoted. Fields set to an
+// expression can't be used as select or delete conditions. After an update the field keeps its
+// old value until the record is selected again. Returns false if the field does not exist.
+func (t *DbTable) SetFieldExpr(fieldName string, expr string, args ...interface{}) bool {
+	return t.setFieldExpr(fieldName, &fieldExpr{expr: expr, args: args})
+}
+
+// Atomically increments a numeric field by the given value when the record is updated,
+// e.g. counter = counter + 1. Can't be used for inserts. Returns false if the field does not exist.
+func (t *DbTable) IncrementField(fieldName string, by string) bool {
+	return t.setFieldExpr(fieldName, &fieldExpr{operator: "+", value: by})
+}
+
+// Atomically decrements a numeric field by the given value when the record is updated,
+// e.g. stock = stock - 1. Can't be used for inserts. Returns false if the field does not exist.
+func (t *DbTable) DecrementField(fieldName string, by string) bool {
+	return t.setFieldExpr(fieldName, &fieldExpr{operator: "-", value: by})
+}
+
+// Returns true if the field has been set to an expression
+func (t DbTable) IsFieldExpr(fieldName string) bool {
+	fId := t.fieldId(fieldName)
+
+	return fId >= 0 && t.fieldExprs[fId] != nil
+}
+
+func (t *DbTable) setFieldExpr(fieldName string, expr *fieldExpr) bool {
+	fId := t.fieldId(fieldName)
+
+	if fId < 0 {
+		return false
+	}
+
+	t.fieldExprs[fId] = expr
+	t.fieldValueSet[fId] = true
+
+	return true
+}
+
+// Builds the sql of the expression set for a field, adding its arguments to the statement arguments.
+// fieldStr is the quoted field used by increments and decrements.
+func (e fieldExpr) buildStr(fieldStr string, fieldType string, args *stmtArgs) (string, error) {
+	if len(e.operator) != 0 {
+		placeholder, err := args.addValue(e.value, fieldType)
+
+		if err != nil {
+			return "", err
+		}
+
+		return fieldStr + " " + e.operator + " " + placeholder, nil
+	}
+
+	parts := strings.Split(e.expr, "?")
+
+	if len(parts)-1 != len(e.args) {
+		return "", fmt.Errorf("Expression %s has %v placeholders, got %v arguments", e.expr, len(parts)-1, len(e.args))
+	}
+
+	exprStr := parts[0]
+
+	for i, arg := range e.args {
+		exprStr = exprStr + args.add(arg) + parts[i+1]
+	}
+
+	return exprStr, nil
+}
@@ -0,0 +1,68 @@
+package dbop
+
+// Table operation a hook is called for
+type HookEvent int
+
+const (
+	BeforeInsert HookEvent = iota
+	AfterInsert
+	BeforeUpdate
+	AfterUpdate
+	BeforeDelete
+	AfterDelete
+	AfterSelect
+)
+
+// Called by table operations with the connection of the operation and the table. Hooks can
+// change the field values of the table. An error returned by a Before hook aborts the operation
+// before anything is written, an error returned by an After hook is returned by the operation
+// after it has been executed, use a transaction to roll it back.
+type Hook func(dbc *DbConnection, t *DbTable) error
+
+// Adds a hook called for an event of the table. Hooks of the same event are called in the order
+// they have been added, the first hook returning an error stops the rest.
+//
+// DoInsert() and DoUpsert() call BeforeInsert and AfterInsert, DoUpdate() and DoUpdateWhere()
+// BeforeUpdate and AfterUpdate, DoDelete(), DoDeleteWhere(), HardDelete() and HardDeleteWhere()
+// BeforeDelete and AfterDelete. AfterDelete is called before the fields are cleared. AfterSelect
+// is called for every selected record, for DoSelect() with the table of the row, but not for the
+// row DoInsert() and DoUpsert() read back. Hooks are copied to the rows returned by DoSelect().
+func (t *DbTable) AddHook(event HookEvent, hook Hook) {
+	if t.hooks == nil {
+		t.hooks = make(map[HookEvent][]Hook)
+	}
+
+	t.hooks[event] = append(t.hooks[event], hook)
+}
+
+// Removes all hooks of the table
+func (t *DbTable) ClearHooks() {
+	t.hooks = nil
+}
+
+func (t *DbTable) runHooks(dbc *DbConnection, event HookEvent) error {
+	for _, hook := range t.hooks[event] {
+		err := hook(dbc, t)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns a copy of the hooks so that hooks added to a table instance don't change the original
+func copyHooks(hooks map[HookEvent][]Hook) map[HookEvent][]Hook {
+	if hooks == nil {
+		return nil
+	}
+
+	hooksCopy := make(map[HookEvent][]Hook, len(hooks))
+
+	for event, eventHooks := range hooks {
+		hooksCopy[event] = append([]Hook(nil), eventHooks...)
+	}
+
+	return hooksCopy
+}
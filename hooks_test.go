@@ -0,0 +1,172 @@
+package dbop
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHookOrder(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var users DbTable
+	createTestTable(t, dbc, &users, NewTableSchema("users",
+		Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}},
+		Column{Name: "slug", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}},
+	))
+
+	var calls []string
+	record := func(name string) Hook {
+		return func(dbc *DbConnection, t *DbTable) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	users.AddHook(BeforeInsert, record("before insert 1"))
+	users.AddHook(BeforeInsert, func(dbc *DbConnection, t *DbTable) error {
+		calls = append(calls, "before insert 2")
+		t.SetFieldValue("slug", "slug-"+t.GetFieldValue("name"))
+		return nil
+	})
+	users.AddHook(AfterInsert, record("after insert"))
+	users.AddHook(BeforeUpdate, record("before update"))
+	users.AddHook(AfterUpdate, record("after update"))
+	users.AddHook(BeforeDelete, record("before delete"))
+	users.AddHook(AfterDelete, func(dbc *DbConnection, t *DbTable) error {
+		calls = append(calls, "after delete "+t.GetFieldValue("name"))
+		return nil
+	})
+	users.AddHook(AfterSelect, record("after select"))
+
+	users.SetFieldValue("name", "ann")
+
+	if err := users.DoInsert(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if users.GetFieldValue("slug") != "slug-ann" {
+		t.Errorf("got slug %q, the value set by the hook is not written", users.GetFieldValue("slug"))
+	}
+
+	users.SetFieldValue("name", "anna")
+
+	if err := users.DoUpdate(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := users.newTableInstance().DoSelect(dbc)
+
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("got %d rows, %v", len(rows), err)
+	}
+
+	// the hooks are copied to the selected rows
+	if err := rows[0].DoDelete(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"before insert 1", "before insert 2", "after insert", "before update", "after update",
+		"after select", "before delete", "after delete anna"}
+
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls\n%v\nwant\n%v", calls, want)
+	}
+
+	// hooks added to an instance don't change the original
+	instance := users.newTableInstance()
+	instance.AddHook(AfterSelect, record("instance"))
+
+	if len(users.hooks[AfterSelect]) != 1 {
+		t.Errorf("got %d AfterSelect hooks on the original", len(users.hooks[AfterSelect]))
+	}
+
+	instance.ClearHooks()
+	calls = nil
+	instance.SetFieldValue("name", "bob")
+
+	if err := instance.DoInsert(dbc); err != nil || len(calls) != 0 {
+		t.Errorf("got calls %v, %v after ClearHooks", calls, err)
+	}
+}
+
+func TestHookAbort(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var users DbTable
+	createTestTable(t, dbc, &users, NewTableSchema("users", Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}}))
+
+	errRejected := errors.New("rejected")
+	var later bool
+
+	rejecting := users.newTableInstance()
+	rejecting.AddHook(BeforeInsert, func(dbc *DbConnection, t *DbTable) error { return errRejected })
+	rejecting.AddHook(BeforeInsert, func(dbc *DbConnection, t *DbTable) error {
+		later = true
+		return nil
+	})
+	rejecting.SetFieldValue("name", "ann")
+
+	if err := rejecting.DoInsert(dbc); !errors.Is(err, errRejected) {
+		t.Errorf("got %v, want the error of the hook", err)
+	}
+
+	if later {
+		t.Errorf("the hook after the failing one has been called")
+	}
+
+	if rows, _ := users.newTableInstance().DoSelect(dbc); len(rows) != 0 {
+		t.Errorf("got %d rows, a failing Before hook must not write", len(rows))
+	}
+
+	// an After hook error is returned after the row has been written
+	failingAfter := users.newTableInstance()
+	failingAfter.AddHook(AfterInsert, func(dbc *DbConnection, t *DbTable) error { return errRejected })
+	failingAfter.SetFieldValue("name", "bob")
+
+	if err := failingAfter.DoInsert(dbc); !errors.Is(err, errRejected) {
+		t.Errorf("got %v, want the error of the hook", err)
+	}
+
+	if rows, _ := users.newTableInstance().DoSelect(dbc); len(rows) != 1 {
+		t.Errorf("got %d rows, the row is written before the After hook", len(rows))
+	}
+
+	// within a transaction the write is rolled back
+	err := dbc.Transaction(func(tx *DbConnection) error {
+		failingAfter.ClearFields()
+		failingAfter.SetFieldValue("name", "carl")
+		return failingAfter.DoInsert(tx)
+	})
+
+	if !errors.Is(err, errRejected) {
+		t.Errorf("got %v, want the error of the hook", err)
+	}
+
+	if rows, _ := users.newTableInstance().DoSelect(dbc); len(rows) != 1 {
+		t.Errorf("got %d rows, the transaction should have been rolled back", len(rows))
+	}
+
+	rejecting.ClearHooks()
+	rejecting.AddHook(BeforeUpdate, func(dbc *DbConnection, t *DbTable) error { return errRejected })
+	rejecting.AddHook(BeforeDelete, func(dbc *DbConnection, t *DbTable) error { return errRejected })
+	rejecting.SetFieldValue("name", "bob")
+
+	if err := rejecting.DoSelectFirstonly(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	rejecting.SetFieldValue("name", "bobby")
+
+	if err := rejecting.DoUpdate(dbc); !errors.Is(err, errRejected) {
+		t.Errorf("update: got %v, want the error of the hook", err)
+	}
+
+	if err := rejecting.DoDelete(dbc); !errors.Is(err, errRejected) {
+		t.Errorf("delete: got %v, want the error of the hook", err)
+	}
+
+	if err := rejecting.DoSelectFirstonlyWhere(dbc, Where("name", "=", "bob")); err != nil {
+		t.Errorf("the rejected update or delete has been written: %v", err)
+	}
+}
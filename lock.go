@@ -0,0 +1,98 @@
+package dbop
+
+import (
+	"fmt"
+)
+
+// Row lock taken by selects, see SetLockMode(). Combine LockForUpdate or LockForShare with
+// LockNoWait or LockSkipLocked, e.g. LockForUpdate | LockSkipLocked.
+type LockMode int
+
+const (
+	LockNone       LockMode = 0
+	LockForUpdate  LockMode = 1 << 0 // exclusive lock, SELECT ... FOR UPDATE
+	LockForShare   LockMode = 1 << 1 // shared lock, SELECT ... FOR SHARE / LOCK IN SHARE MODE
+	LockNoWait     LockMode = 1 << 2 // fail instead of waiting for rows locked by others
+	LockSkipLocked LockMode = 1 << 3 // leave out rows locked by others
+)
+
+// Returns the lock mode as it appears in sql, e.g. FOR UPDATE SKIP LOCKED
+func (mode LockMode) String() string {
+	var lockStr string
+
+	switch {
+	case mode == LockNone:
+		return "NONE"
+	case mode&LockForUpdate != 0:
+		lockStr = "FOR UPDATE"
+	case mode&LockForShare != 0:
+		lockStr = "FOR SHARE"
+	}
+
+	if mode&LockNoWait != 0 {
+		lockStr = lockStr + " NOWAIT"
+	}
+
+	if mode&LockSkipLocked != 0 {
+		lockStr = lockStr + " SKIP LOCKED"
+	}
+
+	return lockStr
+}
+
+// Returns an error if the lock mode is not a valid combination
+func (mode LockMode) validate() error {
+	if mode == LockNone {
+		return nil
+	}
+
+	if mode&^(LockForUpdate|LockForShare|LockNoWait|LockSkipLocked) != 0 {
+		return fmt.Errorf("Unknown lock mode %d", int(mode))
+	}
+
+	if (mode&LockForUpdate != 0) == (mode&LockForShare != 0) {
+		return fmt.Errorf("Lock mode %d must include either LockForUpdate or LockForShare", int(mode))
+	}
+
+	if mode&LockNoWait != 0 && mode&LockSkipLocked != 0 {
+		return fmt.Errorf("Lock mode %d can't include both LockNoWait and LockSkipLocked", int(mode))
+	}
+
+	return nil
+}
+
+// Sets the row lock taken by the following DoSelect(), DoSelectFirstonly() and their Where
+// variants, e.g. for a job queue where competing workers claim rows:
+//
+//	jobsTable.SetLockMode(dbop.LockForUpdate | dbop.LockSkipLocked)
+//	err := jobsTable.DoSelectFirstonly(tx)
+//
+// Locks are held until the transaction ends, so selects with a lock mode must use a connection
+// returned by Begin(). The mode stays in effect until it is set to LockNone.
+func (t *DbTable) SetLockMode(mode LockMode) {
+	t.lockMode = mode
+}
+
+// Returns the row lock taken by selects
+func (t DbTable) LockMode() LockMode {
+	return t.lockMode
+}
+
+// Returns the lock clause of the table lock mode for a select
+func (t DbTable) buildLockStr(dbc *DbConnection) (string, error) {
+	if t.lockMode == LockNone {
+		return "", nil
+	}
+
+	err := t.lockMode.validate()
+
+	if err != nil {
+		return "", err
+	}
+
+	if !dbc.InTransaction() {
+		return "", fmt.Errorf("Lock mode %s can only be used within a transaction", t.lockMode)
+	}
+
+	return dbc.dialect.LockStr(t.lockMode)
+}
@@ -0,0 +1,82 @@
+package dbop
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLockModeStr(t *testing.T) {
+	tests := []struct {
+		mode     LockMode
+		mysql    string
+		postgres string
+	}{
+		{LockForUpdate, "FOR UPDATE", "FOR UPDATE"},
+		{LockForShare, "LOCK IN SHARE MODE", "FOR SHARE"},
+		{LockForUpdate | LockSkipLocked, "FOR UPDATE SKIP LOCKED", "FOR UPDATE SKIP LOCKED"},
+		{LockForShare | LockNoWait, "FOR SHARE NOWAIT", "FOR SHARE NOWAIT"},
+	}
+
+	for _, test := range tests {
+		if got, err := (MySQL{}).LockStr(test.mode); err != nil || got != test.mysql {
+			t.Errorf("MySQL %d: got %q, %v, want %q", int(test.mode), got, err, test.mysql)
+		}
+
+		if got, err := (PostgreSQL{}).LockStr(test.mode); err != nil || got != test.postgres {
+			t.Errorf("PostgreSQL %d: got %q, %v, want %q", int(test.mode), got, err, test.postgres)
+		}
+
+		if _, err := (SQLite{}).LockStr(test.mode); err == nil {
+			t.Errorf("SQLite %d: no error", int(test.mode))
+		}
+	}
+
+	for _, mode := range []LockMode{LockNoWait, LockForUpdate | LockForShare, LockForUpdate | LockNoWait | LockSkipLocked, 1 << 5} {
+		if err := mode.validate(); err == nil {
+			t.Errorf("lock mode %d: no error", int(mode))
+		}
+	}
+}
+
+func TestSelectLockMode(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var jobs DbTable
+	createTestTable(t, dbc, &jobs, NewTableSchema("jobs", Column{Name: "state", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 10}}))
+
+	jobs.SetLockMode(LockForUpdate | LockSkipLocked)
+
+	// the insert reads its row back without the lock, also outside of a transaction
+	jobs.SetFieldValue("state", "new")
+
+	if err := jobs.DoInsert(dbc); err != nil {
+		t.Fatalf("insert with a lock mode: %v", err)
+	}
+
+	if jobs.LockMode() != LockForUpdate|LockSkipLocked {
+		t.Errorf("got lock mode %s after the insert", jobs.LockMode())
+	}
+
+	if _, err := jobs.DoSelectWhere(dbc, Where("state", "=", "new")); err == nil || !strings.Contains(err.Error(), "within a transaction") {
+		t.Errorf("select with a lock mode outside of a transaction: got %v", err)
+	}
+
+	tx, err := dbc.Begin()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err := jobs.DoSelectWhere(tx, Where("state", "=", "new")); err == nil || !strings.Contains(err.Error(), "no row locks") {
+		t.Errorf("SQLite select with a lock mode: got %v", err)
+	}
+
+	tx.dialect = MySQL{}
+	selectStr, _, err := jobs.buildSelectStr(tx, Where("state", "=", "new"), true)
+
+	if err != nil || !strings.HasSuffix(selectStr, " LIMIT 1 FOR UPDATE SKIP LOCKED") {
+		t.Errorf("got %q, %v", selectStr, err)
+	}
+}
@@ -0,0 +1,673 @@
+package dbop
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Describes a single versioned schema change. A migration is either a pair of Go functions or a
+// pair of sql scripts. Up is required, Down is only needed for migrations that can be reverted.
+// Scripts may contain several statements separated by semicolons. Where the dialect supports
+// transactional schema changes, each migration runs in a transaction together with its
+// bookkeeping, the Go functions then get the connection of the transaction.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(dbc *DbConnection) error
+	Down    func(dbc *DbConnection) error
+	UpSQL   string
+	DownSQL string
+}
+
+func (m Migration) hasUp() bool {
+	return m.Up != nil || len(strings.TrimSpace(m.UpSQL)) != 0
+}
+
+func (m Migration) hasDown() bool {
+	return m.Down != nil || len(strings.TrimSpace(m.DownSQL)) != 0
+}
+
+// Describes the state of a single migration as returned by Migrator.Status()
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt string
+	Missing   bool // applied in the database, but not known to the migrator
+}
+
+// Runs versioned migrations against a database connection and keeps track of the applied
+// versions in a bookkeeping table. Migrations are always applied in the order of their version.
+type Migrator struct {
+	dbc         *DbConnection
+	migrations  []Migration
+	tableName   string
+	lockName    string
+	lockTimeout int
+	dryRun      bool
+}
+
+// Returns a new migrator for the connection. The applied versions are stored in the
+// schema_migrations table which is created when needed.
+func (dbc *DbConnection) NewMigrator() *Migrator {
+	return &Migrator{
+		dbc:         dbc,
+		tableName:   "schema_migrations",
+		lockName:    "dbop_schema_migrations",
+		lockTimeout: 60,
+	}
+}
+
+// Sets the name of the bookkeeping table. Must be called before any migrations are run.
+func (m *Migrator) SetTableName(tableName string) {
+	m.tableName = tableName
+	m.lockName = "dbop_" + tableName
+}
+
+// Sets how many seconds to wait for another instance to finish migrating before giving up
+func (m *Migrator) SetLockTimeout(seconds int) {
+	m.lockTimeout = seconds
+}
+
+// In dry run mode the sql of the pending migrations is printed instead of executed and nothing
+// is recorded in the bookkeeping table. Go function migrations are listed, but not called.
+func (m *Migrator) SetDryRun(dryRun bool) {
+	m.dryRun = dryRun
+}
+
+// Adds a migration. Will return an error if the version is already used or the migration has nothing to run.
+func (m *Migrator) Add(migration Migration) error {
+	if migration.Version <= 0 {
+		return fmt.Errorf("Migration %s must have a positive version", migration.Name)
+	}
+
+	if !migration.hasUp() {
+		return fmt.Errorf("Migration %v has no up step", migration.Version)
+	}
+
+	for _, existing := range m.migrations {
+		if existing.Version == migration.Version {
+			return fmt.Errorf("Migration version %v is used by both %s and %s", migration.Version, existing.Name, migration.Name)
+		}
+	}
+
+	m.migrations = append(m.migrations, migration)
+	sort.Slice(m.migrations, func(i, j int) bool { return m.migrations[i].Version < m.migrations[j].Version })
+
+	return nil
+}
+
+// Adds a migration implemented by Go functions. down can be nil.
+func (m *Migrator) AddFunc(version int64, name string, up func(dbc *DbConnection) error, down func(dbc *DbConnection) error) error {
+	return m.Add(Migration{Version: version, Name: name, Up: up, Down: down})
+}
+
+// Adds a migration implemented by sql scripts. downSQL can be empty.
+func (m *Migrator) AddSQL(version int64, name string, upSQL string, downSQL string) error {
+	return m.Add(Migration{Version: version, Name: name, UpSQL: upSQL, DownSQL: downSQL})
+}
+
+// Adds all migrations from .sql files in a directory. Files must be named
+// <version>_<name>.up.sql and <version>_<name>.down.sql, for example 0001_create_users.up.sql.
+// The down file is optional.
+func (m *Migrator) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+
+	if err != nil {
+		return err
+	}
+
+	found := make(map[int64]*Migration)
+	var versions []int64
+
+	for _, file := range files {
+		base := filepath.Base(file)
+		var up bool
+
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			up = true
+			base = strings.TrimSuffix(base, ".up.sql")
+		case strings.HasSuffix(base, ".down.sql"):
+			base = strings.TrimSuffix(base, ".down.sql")
+		default:
+			return fmt.Errorf("Migration file %s must end with .up.sql or .down.sql", file)
+		}
+
+		parts := strings.SplitN(base, "_", 2)
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("Migration file %s does not start with a version number", file)
+		}
+
+		content, err := os.ReadFile(file)
+
+		if err != nil {
+			return err
+		}
+
+		migration, ok := found[version]
+		if !ok {
+			migration = &Migration{Version: version}
+			if len(parts) == 2 {
+				migration.Name = parts[1]
+			}
+			found[version] = migration
+			versions = append(versions, version)
+		}
+
+		if up {
+			migration.UpSQL = string(content)
+		} else {
+			migration.DownSQL = string(content)
+		}
+	}
+
+	for _, version := range versions {
+		err = m.Add(*found[version])
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) migrationsTable() DbTable {
+	var tbl DbTable
+	var recid [2]bool
+
+	tbl.InitTable(m.tableName, []string{"version", "name", "applied_at"}, []string{"BIGINT", "VARCHAR", "DATETIME"}, recid)
+	tbl.SetFieldAttr("version", FieldAttr{NotNull: true})
+	tbl.SetFieldAttr("name", FieldAttr{Size: 255, NotNull: true})
+	tbl.SetFieldAttr("applied_at", FieldAttr{NotNull: true})
+	tbl.AddIndex("version_UNIQUE", true, "version")
+
+	return tbl
+}
+
+// Returns the applied versions from the bookkeeping table mapped to their table rows
+func (m *Migrator) appliedVersions() (map[int64]DbTable, error) {
+	tbl := m.migrationsTable()
+
+	if !m.dryRun {
+		err := tbl.DoCreateTable(m.dbc, true)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if m.dryRun {
+		columns, err := m.dbc.TableColumns(m.tableName)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(columns) == 0 {
+			// the bookkeeping table does not exist yet
+			return make(map[int64]DbTable), nil
+		}
+	}
+
+	// a replica may not have the latest bookkeeping rows yet
+	rows, err := tbl.DoSelect(m.dbc.Primary())
+
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]DbTable)
+
+	for _, row := range rows {
+		version, err := strconv.ParseInt(row.GetFieldValue("version"), 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid version %s in %s", row.GetFieldValue("version"), m.tableName)
+		}
+
+		applied[version] = row
+	}
+
+	return applied, nil
+}
+
+// Takes a named lock on a dedicated connection so that only one instance can migrate at a time.
+// Waiting ends when the context of the connection is done. The returned function releases the
+// lock. Databases without named locks are not locked.
+func (m *Migrator) lock() (func(), error) {
+	lockStr, unlockStr := m.dbc.dialect.NamedLockStr()
+
+	if len(lockStr) == 0 {
+		return func() {}, nil
+	}
+
+	ctx := m.dbc.Context()
+	conn, err := m.dbc.connection.Conn(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(time.Duration(m.lockTimeout) * time.Second)
+
+	for {
+		var locked sql.NullInt64
+		err = conn.QueryRowContext(ctx, lockStr, m.lockName).Scan(&locked)
+
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if locked.Valid && locked.Int64 == 1 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			conn.Close()
+			return nil, fmt.Errorf("Could not get the migration lock %s in %v seconds, another instance is migrating", m.lockName, m.lockTimeout)
+		}
+
+		timer := time.NewTimer(time.Second)
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			conn.Close()
+			return nil, ctx.Err()
+		}
+	}
+
+	return func() {
+		// released even if the context is done, the lock would stay with the pooled connection
+		conn.ExecContext(context.Background(), unlockStr, m.lockName)
+		conn.Close()
+	}, nil
+}
+
+// Splits a sql script into separate statements on semicolons that are not inside quotes or
+// comments. Line comments are removed, block comments are kept as MySQL executes /*! */ comments.
+func splitStatements(dialect Dialect, script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	syntax := dialect.ScriptSyntax()
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		next := byte(0)
+
+		if i+1 < len(script) {
+			next = script[i+1]
+		}
+
+		switch {
+		case c == '\'' || c == '"' || (c == '`' && syntax.Backticks):
+			escapes := syntax.BackslashEscapes && c != '`'
+
+			if syntax.DollarQuotes && c == '\'' && i > 0 && (script[i-1] == 'E' || script[i-1] == 'e') &&
+				(i < 2 || !isIdentByte(script[i-2])) {
+				escapes = true
+			}
+
+			end := quoteEnd(script, i, escapes)
+			current.WriteString(script[i:end])
+			i = end - 1
+
+		case c == '$' && syntax.DollarQuotes && (i == 0 || !isIdentByte(script[i-1])) && dollarTag(script, i) != "":
+			tag := dollarTag(script, i)
+			end := len(script)
+
+			if pos := strings.Index(script[i+len(tag):], tag); pos >= 0 {
+				end = i + len(tag) + pos + len(tag)
+			}
+
+			current.WriteString(script[i:end])
+			i = end - 1
+
+		case c == '/' && next == '*':
+			end := commentEnd(script, i, syntax.NestedComments)
+			current.WriteString(script[i:end])
+			i = end - 1
+
+		case c == '-' && next == '-', c == '#' && syntax.HashComments:
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			current.WriteByte('\n')
+
+		case c == ';':
+			if stmt := strings.TrimSpace(current.String()); len(stmt) != 0 {
+				statements = append(statements, stmt)
+			}
+			current.Reset()
+
+		default:
+			current.WriteByte(c)
+		}
+	}
+
+	if stmt := strings.TrimSpace(current.String()); len(stmt) != 0 {
+		statements = append(statements, stmt)
+	}
+
+	return statements
+}
+
+// Returns the position after the closing quote of the quote starting at start. A doubled
+// quote is part of the quoted text.
+func quoteEnd(script string, start int, escapes bool) int {
+	quote := script[start]
+
+	for i := start + 1; i < len(script); i++ {
+		switch {
+		case escapes && script[i] == '\\':
+			i++
+		case script[i] == quote && i+1 < len(script) && script[i+1] == quote:
+			i++
+		case script[i] == quote:
+			return i + 1
+		}
+	}
+
+	return len(script)
+}
+
+// Returns the position after the end of the block comment starting at start
+func commentEnd(script string, start int, nested bool) int {
+	depth := 0
+
+	for i := start; i+1 < len(script); i++ {
+		switch {
+		case script[i] == '/' && script[i+1] == '*' && (nested || depth == 0):
+			depth++
+			i++
+		case script[i] == '*' && script[i+1] == '/':
+			depth--
+			i++
+
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return len(script)
+}
+
+// Returns the tag of the dollar quote starting at start, e.g. $$ or $body$, or an empty string
+func dollarTag(script string, start int) string {
+	for i := start + 1; i < len(script); i++ {
+		c := script[i]
+
+		if c == '$' {
+			return script[start : i+1]
+		}
+
+		if !isIdentByte(c) || (i == start+1 && c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+
+	return ""
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// Runs a migration step and records it in the bookkeeping table, both within one transaction if
+// the dialect supports transactional schema changes
+func (m *Migrator) apply(migration Migration, up bool) error {
+	if m.dryRun {
+		return m.runStep(m.dbc, migration, up)
+	}
+
+	if !m.dbc.dialect.TransactionalDDL() {
+		err := m.runStep(m.dbc, migration, up)
+
+		if err != nil {
+			return err
+		}
+
+		return m.record(m.dbc, migration, up)
+	}
+
+	return m.dbc.transaction(func(tx *DbConnection) error {
+		err := m.runStep(tx, migration, up)
+
+		if err != nil {
+			return err
+		}
+
+		return m.record(tx, migration, up)
+	})
+}
+
+// Adds an applied migration to the bookkeeping table or removes a reverted one. The bookkeeping
+// is not audited, the audit table may not exist before the migrations have run.
+func (m *Migrator) record(dbc *DbConnection, migration Migration, up bool) error {
+	bookkeeping := *dbc
+	bookkeeping.auditTable = ""
+	dbc = &bookkeeping
+
+	tbl := m.migrationsTable()
+	tbl.SetFieldValue("version", strconv.FormatInt(migration.Version, 10))
+
+	if !up {
+		_, err := tbl.DoDeleteWhere(dbc)
+		return err
+	}
+
+	tbl.SetFieldValue("name", migration.Name)
+	tbl.SetFieldValue("applied_at", dbc.Now().Format(timestampLayout))
+
+	return tbl.DoInsert(dbc)
+}
+
+func (m *Migrator) runStep(dbc *DbConnection, migration Migration, up bool) error {
+	fn := migration.Down
+	script := migration.DownSQL
+	direction := "down"
+
+	if up {
+		fn = migration.Up
+		script = migration.UpSQL
+		direction = "up"
+	}
+
+	if m.dryRun || m.dbc.debug {
+		fmt.Printf("-- migration %v %s (%s)\n", migration.Version, migration.Name, direction)
+	}
+
+	if fn != nil {
+		if m.dryRun {
+			fmt.Printf("-- Go function migration, not run in dry run mode\n")
+			return nil
+		}
+
+		return fn(dbc)
+	}
+
+	for _, stmt := range splitStatements(dbc.dialect, script) {
+		if m.dryRun || m.dbc.debug {
+			fmt.Printf("%s;\n", stmt)
+		}
+
+		if m.dryRun {
+			continue
+		}
+
+		_, err := dbc.Exec(stmt)
+
+		if err != nil {
+			return fmt.Errorf("Migration %v %s failed: %v", migration.Version, direction, err)
+		}
+	}
+
+	return nil
+}
+
+// Applies all pending migrations. Returns the number of migrations applied.
+func (m *Migrator) Up() (int, error) {
+	return m.UpTo(0)
+}
+
+// Applies the pending migrations up to and including the given version. A version of 0 applies
+// all pending migrations. Returns the number of migrations applied.
+func (m *Migrator) UpTo(version int64) (int, error) {
+	var count int
+
+	if !m.dryRun {
+		unlock, err := m.lock()
+
+		if err != nil {
+			return 0, err
+		}
+
+		defer unlock()
+	}
+
+	applied, err := m.appliedVersions()
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, migration := range m.migrations {
+		if version != 0 && migration.Version > version {
+			break
+		}
+
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err = m.apply(migration, true)
+
+		if err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// Reverts the given number of most recently applied migrations. Returns the number of
+// migrations reverted. Will return an error if a migration to revert has no down step or is
+// not known to the migrator.
+func (m *Migrator) Down(steps int) (int, error) {
+	var count int
+	var versions []int64
+
+	if !m.dryRun {
+		unlock, err := m.lock()
+
+		if err != nil {
+			return 0, err
+		}
+
+		defer unlock()
+	}
+
+	applied, err := m.appliedVersions()
+
+	if err != nil {
+		return 0, err
+	}
+
+	for version := range applied {
+		versions = append(versions, version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+	for _, version := range versions {
+		if count >= steps {
+			break
+		}
+
+		migration, ok := m.find(version)
+
+		if !ok {
+			return count, fmt.Errorf("Applied migration %v is not known, can't revert it", version)
+		}
+
+		if !migration.hasDown() {
+			return count, fmt.Errorf("Migration %v %s has no down step", version, migration.Name)
+		}
+
+		err = m.apply(migration, false)
+
+		if err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+
+	return Migration{}, false
+}
+
+// Returns the state of all known migrations and of the applied migrations that are not known
+// to the migrator, ordered by version.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	var statusList []MigrationStatus
+
+	applied, err := m.appliedVersions()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+
+		if row, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = row.GetFieldValue("applied_at")
+		}
+
+		statusList = append(statusList, status)
+	}
+
+	for version, row := range applied {
+		if _, ok := m.find(version); !ok {
+			statusList = append(statusList, MigrationStatus{
+				Version:   version,
+				Name:      row.GetFieldValue("name"),
+				Applied:   true,
+				AppliedAt: row.GetFieldValue("applied_at"),
+				Missing:   true,
+			})
+		}
+	}
+
+	sort.Slice(statusList, func(i, j int) bool { return statusList[i].Version < statusList[j].Version })
+
+	return statusList, nil
+}
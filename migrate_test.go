@@ -0,0 +1,242 @@
+package dbop
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		script  string
+		want    []string
+	}{
+		{"plain", MySQL{}, "CREATE TABLE a (id INT);\n\nINSERT INTO a VALUES (1);;\n",
+			[]string{"CREATE TABLE a (id INT)", "INSERT INTO a VALUES (1)"}},
+		{"no trailing semicolon", SQLite{}, "DROP TABLE a", []string{"DROP TABLE a"}},
+		{"empty", PostgreSQL{}, " ;\n-- nothing here\n", nil},
+		{"quotes", SQLite{}, `INSERT INTO a VALUES ('x;y', 'it''s', "c;d", ` + "`e;f`" + `); SELECT 1`,
+			[]string{`INSERT INTO a VALUES ('x;y', 'it''s', "c;d", ` + "`e;f`" + `)`, "SELECT 1"}},
+		{"mysql backslash escape", MySQL{}, `INSERT INTO a VALUES ('x\';y'); SELECT 1`,
+			[]string{`INSERT INTO a VALUES ('x\';y')`, "SELECT 1"}},
+		{"standard strings keep backslashes", PostgreSQL{}, `INSERT INTO a VALUES ('x\'); SELECT 1`,
+			[]string{`INSERT INTO a VALUES ('x\')`, "SELECT 1"}},
+		{"postgres escape string", PostgreSQL{}, `INSERT INTO a VALUES (E'x\';y'); SELECT 1`,
+			[]string{`INSERT INTO a VALUES (E'x\';y')`, "SELECT 1"}},
+		{"line comments", MySQL{}, "SELECT 1; -- a; b\n# c; d\nSELECT 2",
+			[]string{"SELECT 1", "SELECT 2"}},
+		{"hash is not a comment", PostgreSQL{}, "SELECT '{1}'::jsonb #> '{a}'; SELECT 2",
+			[]string{"SELECT '{1}'::jsonb #> '{a}'", "SELECT 2"}},
+		{"block comments", MySQL{}, "/*!40101 SET NAMES utf8 */; /* a; b */ SELECT 1",
+			[]string{"/*!40101 SET NAMES utf8 */", "/* a; b */ SELECT 1"}},
+		{"nested comments", PostgreSQL{}, "/* a /* b; */ c; */ SELECT 1; SELECT 2",
+			[]string{"/* a /* b; */ c; */ SELECT 1", "SELECT 2"}},
+		{"comments do not nest in mysql", MySQL{}, "/* a /* b */ SELECT 1; SELECT 2",
+			[]string{"/* a /* b */ SELECT 1", "SELECT 2"}},
+		{"dollar quotes", PostgreSQL{},
+			"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql;\n" +
+				"CREATE FUNCTION g() RETURNS int AS $body$ SELECT $1; $body$ LANGUAGE sql",
+			[]string{"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql",
+				"CREATE FUNCTION g() RETURNS int AS $body$ SELECT $1; $body$ LANGUAGE sql"}},
+		{"parameters are not dollar quotes", PostgreSQL{}, "SELECT $1; SELECT $2",
+			[]string{"SELECT $1", "SELECT $2"}},
+	}
+
+	for _, test := range tests {
+		got := splitStatements(test.dialect, test.script)
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	dbc := openTestDB(t)
+	dbc.SetClock(func() time.Time { return time.Date(2015, 1, 1, 12, 0, 0, 0, time.UTC) })
+
+	newMigrator := func() *Migrator {
+		m := dbc.NewMigrator()
+
+		err := m.AddSQL(1, "create_users", "CREATE TABLE users (id INTEGER, name VARCHAR(20));\nCREATE INDEX users_id ON users (id);",
+			"DROP TABLE users")
+
+		if err == nil {
+			err = m.AddFunc(2, "seed_users", func(tx *DbConnection) error {
+				_, err := tx.Exec("INSERT INTO users (id, name) VALUES (1, 'ann')")
+				return err
+			}, func(tx *DbConnection) error {
+				_, err := tx.Exec("DELETE FROM users")
+				return err
+			})
+		}
+
+		if err == nil {
+			err = m.AddSQL(3, "create_roles", "CREATE TABLE roles (id INTEGER)", "")
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return m
+	}
+
+	applied := func(m *Migrator) []int64 {
+		var versions []int64
+
+		statusList, err := m.Status()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, status := range statusList {
+			if status.Applied {
+				versions = append(versions, status.Version)
+			}
+		}
+
+		return versions
+	}
+
+	m := newMigrator()
+	m.SetDryRun(true)
+
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	if columns, err := dbc.TableColumns("schema_migrations"); err != nil || len(columns) != 0 {
+		t.Fatalf("dry run created the bookkeeping table, %v", err)
+	}
+
+	m.SetDryRun(false)
+
+	if n, err := m.UpTo(2); err != nil || n != 2 {
+		t.Fatalf("UpTo applied %d, %v", n, err)
+	}
+
+	statusList, err := m.Status()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []MigrationStatus{
+		{Version: 1, Name: "create_users", Applied: true, AppliedAt: statusList[0].AppliedAt},
+		{Version: 2, Name: "seed_users", Applied: true, AppliedAt: statusList[1].AppliedAt},
+		{Version: 3, Name: "create_roles"},
+	}
+
+	if !reflect.DeepEqual(statusList, want) {
+		t.Errorf("got status %+v, want %+v", statusList, want)
+	}
+
+	if !strings.HasPrefix(strings.Replace(statusList[0].AppliedAt, "T", " ", 1), "2015-01-01 12:00:00") {
+		t.Errorf("applied at %s, not by the clock of the connection", statusList[0].AppliedAt)
+	}
+
+	if n, err := m.Down(1); err != nil || n != 1 {
+		t.Fatalf("Down reverted %d, %v", n, err)
+	}
+
+	if got := applied(m); !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("applied %v after Down", got)
+	}
+
+	if n, err := m.Up(); err != nil || n != 2 {
+		t.Fatalf("Up applied %d, %v", n, err)
+	}
+
+	if n, err := m.Down(1); err == nil || n != 0 {
+		t.Errorf("Down of a migration without down step reverted %d, %v", n, err)
+	}
+
+	// a failing migration is rolled back together with its bookkeeping
+	err = m.AddSQL(4, "broken", "CREATE TABLE jobs (id INTEGER); INSERT INTO unknown VALUES (1)", "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := m.Up(); err == nil || n != 0 {
+		t.Errorf("broken migration applied %d, %v", n, err)
+	}
+
+	if columns, _ := dbc.TableColumns("jobs"); len(columns) != 0 {
+		t.Errorf("broken migration was not rolled back")
+	}
+
+	if got := applied(m); !reflect.DeepEqual(got, []int64{1, 2, 3}) {
+		t.Errorf("applied %v after a broken migration", got)
+	}
+
+	// versions applied by another release of the application
+	other := dbc.NewMigrator()
+	other.AddSQL(1, "create_users", "CREATE TABLE users (id INTEGER)", "")
+
+	statusList, err = other.Status()
+
+	if err != nil || len(statusList) != 3 || !statusList[2].Missing || statusList[2].Name != "create_roles" {
+		t.Errorf("got status %+v, %v", statusList, err)
+	}
+}
+
+func TestMigratorAudit(t *testing.T) {
+	dbc := openTestDB(t)
+	dbc.EnableAudit("audit_log")
+
+	var audit, users DbTable
+	err := audit.InitSchema(AuditTableSchema("audit_log"))
+
+	if err == nil {
+		err = users.InitSchema(NewTableSchema("users",
+			Column{Name: "recid", Type: "BIGINT", AutoIncrement: true, PrimaryKey: true},
+			Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}},
+		))
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the audit table is created by a migration, the bookkeeping of the ones before is not audited
+	m := dbc.NewMigrator()
+	err = m.AddFunc(1, "create_tables", func(tx *DbConnection) error {
+		if err := audit.DoCreateTable(tx, false); err != nil {
+			return err
+		}
+
+		return users.DoCreateTable(tx, false)
+	}, nil)
+
+	if err == nil {
+		err = m.AddFunc(2, "seed_users", func(tx *DbConnection) error {
+			seed := users.newTableInstance()
+			seed.SetFieldValue("name", "ann")
+			return seed.DoInsert(tx)
+		}, nil)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := m.Up(); err != nil || n != 2 {
+		t.Fatalf("Up applied %d, %v", n, err)
+	}
+
+	entries, err := audit.DoSelect(dbc)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].GetFieldValue("table_name") != "users" {
+		t.Errorf("got %d audit entries, want only the seeded user", len(entries))
+	}
+}
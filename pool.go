@@ -0,0 +1,106 @@
+package dbop
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Options for opening a connection with OpenWithOptions(). Pool settings left at 0 keep the
+// database/sql defaults.
+type OpenOptions struct {
+	Debug          bool
+	TimeZoneOffset string
+
+	MaxOpenConns    int           // maximum number of open connections, unlimited by default
+	MaxIdleConns    int           // maximum number of idle connections, a negative value keeps none
+	ConnMaxLifetime time.Duration // maximum time a connection is reused
+	ConnMaxIdleTime time.Duration // maximum time a connection stays idle
+}
+
+// Statistics of the connection pool and the prepared statement cache of a connection
+type PoolStats struct {
+	sql.DBStats
+	Statements StatementCacheStats
+}
+
+// Opens a database connection like OpenDriver() and configures its connection pool
+func (dbc *DbConnection) OpenWithOptions(driverName string, connectionStr string, opts OpenOptions) error {
+	err := dbc.OpenDriver(driverName, connectionStr, opts.Debug, opts.TimeZoneOffset)
+
+	if err != nil {
+		return err
+	}
+
+	dbc.SetPoolOptions(opts)
+
+	return nil
+}
+
+// Applies the pool settings of the options to an opened connection. Debug and TimeZoneOffset
+// are ignored.
+func (dbc *DbConnection) SetPoolOptions(opts OpenOptions) {
+	setPoolOptions(dbc.connection, opts)
+}
+
+func setPoolOptions(db *sql.DB, opts OpenOptions) {
+	if opts.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+
+	if opts.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+
+	if opts.ConnMaxLifetime != 0 {
+		db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+
+	if opts.ConnMaxIdleTime != 0 {
+		db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	}
+}
+
+// Verifies the database can be reached, opening a connection if necessary
+func (dbc *DbConnection) Ping() error {
+	if dbc.connection == nil {
+		return fmt.Errorf("Connection has not been opened")
+	}
+
+	return dbc.connection.PingContext(dbc.Context())
+}
+
+// Returns the statistics of the connection pool and the prepared statement cache
+func (dbc *DbConnection) Stats() PoolStats {
+	var stats PoolStats
+
+	if dbc.connection != nil {
+		stats.DBStats = dbc.connection.Stats()
+	}
+
+	stats.Statements = dbc.StatementCacheStats()
+
+	return stats
+}
+
+// Returns nil if the connection can serve requests, meant for the readiness check of a service.
+// The database must answer a ping within the timeout, or before the context of the connection
+// is done if the timeout is 0.
+func (dbc *DbConnection) Ready(timeout time.Duration) error {
+	ctx := dbc.Context()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := dbc.WithContext(ctx).Ping()
+
+	if err != nil {
+		return fmt.Errorf("Database is not ready: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package dbop
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenWithOptions(t *testing.T) {
+	var dbc DbConnection
+
+	err := dbc.OpenWithOptions("sqlite3", filepath.Join(t.TempDir(), "test.db"), OpenOptions{MaxOpenConns: 3, MaxIdleConns: 2})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer dbc.Close()
+
+	dbc.SetStatementCacheSize(5)
+
+	if err := dbc.Ready(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dbc.Exec("CREATE TABLE items (name VARCHAR(20))"); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := dbc.Stats()
+
+	if stats.MaxOpenConnections != 3 || stats.OpenConnections == 0 || stats.OpenConnections > 3 {
+		t.Errorf("got max open %d, open %d", stats.MaxOpenConnections, stats.OpenConnections)
+	}
+
+	if stats.Statements.Capacity != 5 {
+		t.Errorf("got statement cache capacity %d", stats.Statements.Capacity)
+	}
+
+	if err := dbc.Ready(0); err != nil {
+		t.Errorf("without a timeout: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := dbc.WithContext(ctx).Ready(0); err == nil {
+		t.Errorf("ready with a done context")
+	}
+}
+
+func TestReadyNotOpen(t *testing.T) {
+	var dbc DbConnection
+
+	if err := dbc.Ready(time.Second); err == nil {
+		t.Errorf("a connection that has not been opened is ready")
+	}
+
+	if stats := dbc.Stats(); stats.OpenConnections != 0 || stats.Statements.Size != 0 {
+		t.Errorf("got stats %+v", stats)
+	}
+
+	closed := openTestDB(t)
+	closed.Close()
+
+	if err := closed.Ready(time.Second); err == nil {
+		t.Errorf("a closed connection is ready")
+	}
+}
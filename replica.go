@@ -0,0 +1,127 @@
+package dbop
+
+import (
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+)
+
+// How selects are distributed over the replicas of a connection
+type ReplicaPolicy int
+
+const (
+	RoundRobin       ReplicaPolicy = iota // each select goes to the next replica
+	LeastConnections                      // each select goes to the replica with the fewest connections in use
+)
+
+// A read replica of the primary database
+type replica struct {
+	db    *sql.DB
+	stmts *stmtCache
+}
+
+// Adds a read replica of the primary database. DoSelect(), DoSelectFirstonly() and their Where
+// variants are then routed to the replicas, while writes, selects with a lock mode and everything
+// within a transaction go to the primary. Use Primary() for reads that must see preceding writes.
+// Add replicas before creating connections with WithContext() or Begin(). Close() closes the
+// replicas.
+func (dbc *DbConnection) AddReplica(db *sql.DB) error {
+	if db == nil {
+		return fmt.Errorf("db can't be nil")
+	}
+
+	if dbc.replicaNext == nil {
+		dbc.replicaNext = new(uint32)
+	}
+
+	rep := &replica{db: db}
+
+	if dbc.stmts != nil {
+		rep.stmts = newStmtCache(dbc.stmts.capacity)
+	}
+
+	dbc.replicas = append(dbc.replicas, rep)
+
+	return nil
+}
+
+// Opens the replica described by the config and adds it with AddReplica(). Debug of the config
+// is ignored, its time zone is set on the connections of the replica like OpenDriver() does.
+func (dbc *DbConnection) AddReplicaConfig(cfg Config) error {
+	dsn, err := cfg.DSN()
+
+	if err != nil {
+		return err
+	}
+
+	var db *sql.DB
+
+	if len(cfg.TimeZone) != 0 {
+		db, err = openTimeZoneDB(cfg.Driver, dsn, DialectForDriver(cfg.Driver), cfg.TimeZone)
+	} else {
+		db, err = sql.Open(cfg.Driver, dsn)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	setPoolOptions(db, OpenOptions{
+		MaxOpenConns:    cfg.MaxOpenConns,
+		MaxIdleConns:    cfg.MaxIdleConns,
+		ConnMaxLifetime: cfg.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+	})
+
+	return dbc.AddReplica(db)
+}
+
+// Sets how selects are distributed over the replicas, RoundRobin by default
+func (dbc *DbConnection) SetReplicaPolicy(policy ReplicaPolicy) {
+	dbc.replicaPolicy = policy
+}
+
+// Returns the number of replicas of the connection
+func (dbc *DbConnection) ReplicaCount() int {
+	return len(dbc.replicas)
+}
+
+// Returns a copy of the connection executing all selects on the primary database, for reads
+// that must see the writes made just before
+func (dbc *DbConnection) Primary() *DbConnection {
+	primc := *dbc
+	primc.primaryReads = true
+
+	return &primc
+}
+
+// Returns the connection a select with the lock mode is executed on, a copy of the connection
+// using a replica if the select can go to one
+func (dbc *DbConnection) readConnection(lockMode LockMode) *DbConnection {
+	if len(dbc.replicas) == 0 || dbc.primaryReads || dbc.tx != nil || lockMode != LockNone {
+		return dbc
+	}
+
+	var rep *replica
+
+	switch dbc.replicaPolicy {
+	case LeastConnections:
+		inUse := -1
+
+		for _, candidate := range dbc.replicas {
+			if candidateInUse := candidate.db.Stats().InUse; inUse < 0 || candidateInUse < inUse {
+				rep = candidate
+				inUse = candidateInUse
+			}
+		}
+	default:
+		next := atomic.AddUint32(dbc.replicaNext, 1)
+		rep = dbc.replicas[(next-1)%uint32(len(dbc.replicas))]
+	}
+
+	repc := *dbc
+	repc.connection = rep.db
+	repc.stmts = rep.stmts
+
+	return &repc
+}
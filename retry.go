@@ -0,0 +1,153 @@
+package dbop
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Describes how operations failing with a transient error are retried, see SetRetryPolicy()
+type RetryPolicy struct {
+	MaxAttempts    int                  // attempts including the first one, 1 or less turns retrying off
+	InitialBackoff time.Duration        // wait before the first retry, doubled for every following one
+	MaxBackoff     time.Duration        // upper limit of the wait, unlimited if 0
+	Retryable      func(err error) bool // classifies the errors, IsRetryable() if nil
+}
+
+// MySQL error numbers of failures that are gone when the operation is repeated
+var retryableMySQLErrors = map[int]bool{
+	1040: true, // too many connections
+	1205: true, // lock wait timeout exceeded
+	1213: true, // deadlock found when trying to get lock
+	2002: true, // can't connect through socket
+	2003: true, // can't connect to server
+	2006: true, // server has gone away
+	2013: true, // lost connection during query
+}
+
+// Matches the error number in the messages of the go-sql-driver (Error 1213) and mymysql (#1213) drivers
+var mySQLErrorNumber = regexp.MustCompile(`(?:Error |#)(\d{4})\b`)
+
+// Message of the ErrInvalidConn of the go-sql-driver, returned for a connection that broke
+const mySQLInvalidConn = "invalid connection"
+
+// Returns true if the error is transient, i.e. a deadlock, a lock wait timeout, a lost or
+// refused connection or too many connections. Errors of a canceled context or an exceeded
+// deadline are not, whatever the driver reports along with them.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if strings.Contains(err.Error(), mySQLInvalidConn) {
+		return true
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	match := mySQLErrorNumber.FindStringSubmatch(err.Error())
+
+	if match == nil {
+		return false
+	}
+
+	number, _ := strconv.Atoi(match[1])
+
+	return retryableMySQLErrors[number]
+}
+
+// Sets the connection to retry operations failing with a transient error, waiting with an
+// exponential backoff and jitter between the attempts. Retried are the selects, DoUpdate(),
+// DoUpdateWhere() and DoUpsert() unless fields are set to expressions, DoDelete(),
+// DoDeleteWhere() and whole Transaction() closures, which must be safe to run again. Inserts
+// and statements within a transaction are not retried.
+// A connection lost while the commit was under way leaves it unknown whether the write has been
+// applied. The retry then runs against the written row, so DoUpdate() with a version field can
+// return ErrStaleRecord and DoUpdate() or DoDelete() an error about 0 affected lines for a write
+// that succeeded, and DoUpdateWhere() can change the version twice.
+func (dbc *DbConnection) SetRetryPolicy(policy RetryPolicy) {
+	dbc.retryPolicy = policy
+}
+
+// Returns the retry policy of the connection
+func (dbc *DbConnection) RetryPolicy() RetryPolicy {
+	return dbc.retryPolicy
+}
+
+// Runs fn, repeating it on transient errors if the operation is idempotent and the retry policy
+// allows it. Returns the error of the last attempt.
+func (dbc *DbConnection) retry(idempotent bool, fn func() error) error {
+	policy := dbc.retryPolicy
+
+	if !idempotent || policy.MaxAttempts <= 1 || dbc.tx != nil {
+		return fn()
+	}
+
+	retryable := policy.Retryable
+
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	backoff := policy.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+
+		if err == nil || attempt >= policy.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+
+		dbc.debugPrint("RETRY "+strconv.Itoa(attempt)+": "+err.Error(), nil)
+
+		if backoff > 0 {
+			// wait between half and the full backoff
+			wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+			timer := time.NewTimer(wait)
+
+			select {
+			case <-timer.C:
+			case <-dbc.Context().Done():
+				timer.Stop()
+				return err
+			}
+		}
+
+		backoff *= 2
+	}
+}
+
+// Returns true if repeating a write of the set fields has the same result, which is not the
+// case for fields set to expressions like increments
+func (t DbTable) idempotent() bool {
+	for _, expr := range t.fieldExprs {
+		if expr != nil {
+			return false
+		}
+	}
+
+	return true
+}
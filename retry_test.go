@@ -0,0 +1,85 @@
+package dbop
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"bad connection", driver.ErrBadConn, true},
+		{"wrapped bad connection", fmt.Errorf("Insert: %w", driver.ErrBadConn), true},
+		{"eof", io.EOF, true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"go-sql-driver invalid connection", errors.New("invalid connection"), true},
+		{"network error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"deadlock", errors.New("Error 1213 (40001): Deadlock found when trying to get lock; try restarting transaction"), true},
+		{"lock wait timeout", errors.New("Error 1205 (HY000): Lock wait timeout exceeded; try restarting transaction"), true},
+		{"too many connections", errors.New("Error 1040: Too many connections"), true},
+		{"server gone away", errors.New("Error 2006: MySQL server has gone away"), true},
+		{"mymysql deadlock", errors.New("Received #1213 error from MySQL server: \"Deadlock found\""), true},
+		{"duplicate key", errors.New("Error 1062 (23000): Duplicate entry '1' for key 'PRIMARY'"), false},
+		{"five digit number", errors.New("Error 12130: unknown"), false},
+		{"no rows", sql.ErrNoRows, false},
+		{"stale record", ErrStaleRecord, false},
+		{"deadline exceeded", context.DeadlineExceeded, false},
+		{"canceled", context.Canceled, false},
+		{"wrapped canceled", fmt.Errorf("Select: %w", context.Canceled), false},
+		{"network timeout of the deadline", &net.OpError{Op: "read", Net: "tcp", Err: context.DeadlineExceeded}, false},
+		{"bad connection of the deadline", fmt.Errorf("%w: %w", driver.ErrBadConn, context.DeadlineExceeded), false},
+	}
+
+	for _, test := range tests {
+		if got := IsRetryable(test.err); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var dbc DbConnection
+	dbc.SetRetryPolicy(RetryPolicy{MaxAttempts: 3})
+
+	tests := []struct {
+		name       string
+		idempotent bool
+		errs       []error // returned by the attempts, nil after the last one
+		attempts   int
+		fails      bool
+	}{
+		{"succeeds", true, nil, 1, false},
+		{"recovers", true, []error{driver.ErrBadConn, driver.ErrBadConn}, 3, false},
+		{"gives up", true, []error{driver.ErrBadConn, driver.ErrBadConn, driver.ErrBadConn}, 3, true},
+		{"permanent error", true, []error{sql.ErrNoRows}, 1, true},
+		{"not idempotent", false, []error{driver.ErrBadConn}, 1, true},
+	}
+
+	for _, test := range tests {
+		attempts := 0
+
+		err := dbc.retry(test.idempotent, func() error {
+			attempts++
+
+			if attempts <= len(test.errs) {
+				return test.errs[attempts-1]
+			}
+
+			return nil
+		})
+
+		if attempts != test.attempts || (err != nil) != test.fails {
+			t.Errorf("%s: got %d attempts and error %v", test.name, attempts, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package dbop
+
+import (
+	"fmt"
+)
+
+// Describes a column of a table. FieldAttr holds the size, scale, nullability, default and
+// unsigned attributes. Only the recid column can be the primary key and auto increment.
+type Column struct {
+	Name string
+	Type string // field type, e.g. VARCHAR
+	FieldAttr
+	AutoIncrement bool
+	PrimaryKey    bool
+	Comment       string
+}
+
+// Describes a table built from its columns. A column named recid is used as the recid field
+// of the table and must be its primary key.
+type TableSchema struct {
+	Name    string
+	Columns []Column
+	Indexes []DbIndex
+}
+
+// Returns a schema of the table with the columns
+func NewTableSchema(tableName string, columns ...Column) TableSchema {
+	return TableSchema{Name: tableName, Columns: columns}
+}
+
+// Adds an index over one or more columns to the schema
+func (s *TableSchema) AddIndex(name string, unique bool, fieldNames ...string) {
+	s.Indexes = append(s.Indexes, DbIndex{Name: name, Unique: unique, Fields: fieldNames})
+}
+
+// Initiates the table from a schema. Returns an error if the schema is invalid, e.g. has
+// duplicate column names or a primary key other than recid.
+func (t *DbTable) InitSchema(schema TableSchema) error {
+	var columns []Column
+	var recid RecId
+
+	if len(schema.Name) == 0 {
+		return fmt.Errorf("Table name can't be empty")
+	}
+
+	names := make(map[string]bool)
+
+	for _, column := range schema.Columns {
+		if len(column.Name) == 0 {
+			return fmt.Errorf("Table %s has a column without a name", schema.Name)
+		}
+
+		if names[column.Name] {
+			return fmt.Errorf("Table %s has more than one column %s", schema.Name, column.Name)
+		}
+
+		names[column.Name] = true
+
+		if column.Name == "recid" {
+			if !column.PrimaryKey {
+				return fmt.Errorf("Table %s: recid must be the primary key", schema.Name)
+			}
+
+			recid.Exists = true
+			recid.AutoInc = column.AutoIncrement
+			continue
+		}
+
+		if column.PrimaryKey || column.AutoIncrement {
+			return fmt.Errorf("Table %s: only recid can be the primary key or auto increment, not %s", schema.Name, column.Name)
+		}
+
+		columns = append(columns, column)
+	}
+
+	for _, index := range schema.Indexes {
+		for _, fieldName := range index.Fields {
+			if !names[fieldName] {
+				return fmt.Errorf("Table %s: index %s has an unknown field %s", schema.Name, index.Name, fieldName)
+			}
+		}
+	}
+
+	t.initColumns(schema.Name, columns, recid)
+	t.indexes = append([]DbIndex(nil), schema.Indexes...)
+
+	return nil
+}
+
+// Returns the schema of the table, the recid column first if the table uses recid
+func (t DbTable) Schema() TableSchema {
+	schema := TableSchema{Name: t.tableName, Indexes: append([]DbIndex(nil), t.indexes...)}
+
+	if t.recid.Exists {
+		schema.Columns = append(schema.Columns, Column{Name: "recid", Type: "BIGINT", FieldAttr: FieldAttr{NotNull: true},
+			AutoIncrement: t.recid.AutoInc, PrimaryKey: true})
+	}
+
+	schema.Columns = append(schema.Columns, t.columns...)
+
+	return schema
+}
+
+// Returns the column of a field. The method will panic if the field does not exist.
+func (t DbTable) GetColumn(fieldName string) Column {
+	fId := t.fieldId(fieldName)
+
+	if fId < 0 {
+		panic("Field not found")
+	}
+
+	return t.columns[fId]
+}
+
+// Sets the columns and allocates the field values of the table
+func (t *DbTable) initColumns(tableName string, columns []Column, recid RecId) {
+	t.tableName = tableName
+	t.columns = columns
+	t.fieldValue = make([]string, len(columns))
+	t.fieldValueSet = make([]bool, len(columns))
+	t.fieldExprs = make([]*fieldExpr, len(columns))
+	t.fieldOrig = make([]string, len(columns))
+	t.fieldRules = make([][]Rule, len(columns))
+	t.loaded = false
+	t.indexes = nil
+	t.recid = RecId{Exists: recid.Exists, AutoInc: recid.Exists && recid.AutoInc}
+}
@@ -0,0 +1,83 @@
+package dbop
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInitSchema(t *testing.T) {
+	var tbl DbTable
+
+	schema := NewTableSchema("users",
+		Column{Name: "recid", Type: "BIGINT", FieldAttr: FieldAttr{NotNull: true}, AutoIncrement: true, PrimaryKey: true},
+		Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45, NotNull: true}},
+		Column{Name: "rating", Type: "DECIMAL", Comment: "average rating"},
+	)
+	schema.AddIndex("name_idx", true, "name")
+
+	if err := tbl.InitSchema(schema); err != nil {
+		t.Fatal(err)
+	}
+
+	if !tbl.recid.Exists || !tbl.recid.AutoInc {
+		t.Errorf("got recid %+v", tbl.recid)
+	}
+
+	if got := tbl.GetFieldNameList(); !reflect.DeepEqual(got, []string{"name", "rating"}) {
+		t.Errorf("got fields %v", got)
+	}
+
+	if got := tbl.GetColumn("rating").Comment; got != "average rating" {
+		t.Errorf("got comment %s", got)
+	}
+
+	if got := tbl.Schema(); !reflect.DeepEqual(got, schema) {
+		t.Errorf("got schema %+v, want %+v", got, schema)
+	}
+}
+
+func TestInitSchemaErrors(t *testing.T) {
+	name := Column{Name: "name", Type: "VARCHAR"}
+
+	withIndex := NewTableSchema("users", name)
+	withIndex.AddIndex("email_idx", false, "email")
+
+	tests := map[string]TableSchema{
+		"no table name":        NewTableSchema("", name),
+		"column without name":  NewTableSchema("users", Column{Type: "INT"}),
+		"duplicate column":     NewTableSchema("users", name, name),
+		"recid not the key":    NewTableSchema("users", Column{Name: "recid", Type: "BIGINT"}, name),
+		"other primary key":    NewTableSchema("users", Column{Name: "id", Type: "BIGINT", PrimaryKey: true}),
+		"other auto increment": NewTableSchema("users", Column{Name: "id", Type: "BIGINT", AutoIncrement: true}),
+		"unknown index field":  withIndex,
+	}
+
+	for testName, schema := range tests {
+		var tbl DbTable
+
+		if err := tbl.InitSchema(schema); err == nil {
+			t.Errorf("%s: no error", testName)
+		}
+	}
+}
+
+func TestInitTableLenient(t *testing.T) {
+	var tbl DbTable
+
+	// accepted as before InitSchema() existed
+	tbl.InitTable("", []string{"recid", "name", "note"}, []string{"BIGINT", "VARCHAR"}, [2]bool{false, false})
+
+	if got := tbl.GetFieldNameList(); !reflect.DeepEqual(got, []string{"recid", "name"}) {
+		t.Errorf("got fields %v", got)
+	}
+
+	if tbl.recid.Exists {
+		t.Errorf("recid exists")
+	}
+
+	tbl.InitTable("users", []string{"name"}, []string{"VARCHAR", "INT"}, [2]bool{true, true})
+
+	if got := tbl.GetFieldNameList(); !reflect.DeepEqual(got, []string{"name", ""}) {
+		t.Errorf("got fields %v", got)
+	}
+}
@@ -0,0 +1,135 @@
+package dbop
+
+import (
+	"fmt"
+)
+
+// Which rows of a soft deleted table are selected
+type deletedScope int
+
+const (
+	withoutDeleted deletedScope = iota
+	withDeleted
+	onlyDeleted
+)
+
+// Sets the field marking rows as deleted. DoDelete() and DoDeleteWhere() then set the field to
+// the current time instead of deleting the rows, and selects leave out the rows where the field
+// is not NULL. Use WithDeleted() and OnlyDeleted() to select deleted rows, Restore() to undo a
+// delete and HardDelete() to really delete a row. The field must be a DATETIME, TIMESTAMP or
+// DATE field that is NULL for rows that have not been deleted. Panics if the field does not exist.
+func (t *DbTable) SetSoftDeleteField(fieldName string) {
+	if t.fieldId(fieldName) < 0 {
+		panic("Field not found")
+	}
+
+	t.softDeleteField = fieldName
+}
+
+// Returns the soft delete field of the table or an empty string if rows are really deleted
+func (t DbTable) SoftDeleteField() string {
+	return t.softDeleteField
+}
+
+// Sets the following selects to include the soft deleted rows
+func (t *DbTable) WithDeleted() *DbTable {
+	t.deletedScope = withDeleted
+	return t
+}
+
+// Sets the following selects to return only the soft deleted rows
+func (t *DbTable) OnlyDeleted() *DbTable {
+	t.deletedScope = onlyDeleted
+	return t
+}
+
+// Sets the following selects to leave out the soft deleted rows, which is the default
+func (t *DbTable) WithoutDeleted() *DbTable {
+	t.deletedScope = withoutDeleted
+	return t
+}
+
+// Restores the selected soft deleted record by setting its soft delete field back to NULL
+func (t *DbTable) Restore(dbcon *DbConnection) error {
+	fId := t.fieldId(t.softDeleteField)
+
+	if fId < 0 {
+		return fmt.Errorf("Table %s has no soft delete field", t.tableName)
+	}
+
+	if !t.recid.Exists || t.recid.IsSet || t.recid.Value == 0 {
+		return fmt.Errorf("No record has been selected, cant Restore()!")
+	}
+
+	tbl := t.newTableInstance()
+	tbl.SetFieldExpr(t.softDeleteField, "NULL")
+
+	rows, err := tbl.execUpdate(dbcon, t.recIdCondition(), false)
+
+	if err != nil {
+		return err
+	}
+
+	if rows != 1 {
+		return fmt.Errorf("Something went wrong, %v lines restored.", rows)
+	}
+
+	t.fieldValue[fId] = ""
+	t.fieldOrig[fId] = ""
+
+	return nil
+}
+
+// Deletes the selected record from the table, also if the table has a soft delete field
+func (t *DbTable) HardDelete(dbcon *DbConnection) error {
+	return t.deleteRecord(dbcon, true)
+}
+
+// Deletes all lines matching the set field values from the table like DoDeleteWhere(),
+// also if the table has a soft delete field
+func (t *DbTable) HardDeleteWhere(dbcon *DbConnection) (int64, error) {
+	return t.deleteWhere(dbcon, true)
+}
+
+// Marks the rows matching the condition that have not been deleted yet as deleted
+func (t DbTable) softDelete(dbcon *DbConnection, where *Condition) (int64, error) {
+	if where.IsEmpty() {
+		return 0, fmt.Errorf("Delete must have a where clause")
+	}
+
+	fId := t.fieldId(t.softDeleteField)
+	value, err := timestampValue(dbcon, t.softDeleteField, t.columns[fId].Type)
+
+	if err != nil {
+		return 0, err
+	}
+
+	tbl := t.newTableInstance()
+	tbl.SetFieldValue(t.softDeleteField, value)
+
+	return tbl.execUpdate(dbcon, WhereGroup(where).And(t.softDeleteField, "IS NULL"), false)
+}
+
+// Adds the soft delete condition of the deleted scope to a select condition
+func (t DbTable) scopeCondition(where *Condition) *Condition {
+	var operator string
+
+	if len(t.softDeleteField) == 0 {
+		return where
+	}
+
+	switch t.deletedScope {
+	case withDeleted:
+		return where
+	case onlyDeleted:
+		operator = "IS NOT NULL"
+	default:
+		operator = "IS NULL"
+	}
+
+	if where.IsEmpty() {
+		return Where(t.softDeleteField, operator)
+	}
+
+	return WhereGroup(where).And(t.softDeleteField, operator)
+}
@@ -0,0 +1,132 @@
+package dbop
+
+import "testing"
+
+func TestSoftDelete(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var users DbTable
+	createTestTable(t, dbc, &users, NewTableSchema("users",
+		Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}},
+		Column{Name: "deleted_at", Type: "DATETIME"},
+	))
+	users.SetSoftDeleteField("deleted_at")
+
+	for _, name := range []string{"ann", "bob"} {
+		users.ClearFields()
+		users.SetFieldValue("name", name)
+
+		if err := users.DoInsert(dbc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func(tbl *DbTable) int {
+		rows, err := tbl.DoSelect(dbc)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tbl.WithoutDeleted()
+
+		return len(rows)
+	}
+
+	// bob is the selected record
+	if err := users.DoDelete(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	scope := users.newTableInstance()
+
+	if n := count(&scope); n != 1 {
+		t.Errorf("got %d rows without the deleted", n)
+	}
+
+	if n := count(scope.WithDeleted()); n != 2 {
+		t.Errorf("got %d rows with the deleted", n)
+	}
+
+	if n := count(scope.OnlyDeleted()); n != 1 {
+		t.Errorf("got %d deleted rows", n)
+	}
+
+	deleted := users.newTableInstance()
+	deleted.OnlyDeleted()
+
+	if err := deleted.DoSelectFirstonly(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted.GetFieldValue("name") != "bob" || len(deleted.GetFieldValue("deleted_at")) == 0 {
+		t.Errorf("got %s deleted at %s", deleted.GetFieldValue("name"), deleted.GetFieldValue("deleted_at"))
+	}
+
+	if err := deleted.Restore(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(&scope); n != 2 {
+		t.Errorf("got %d rows after the restore", n)
+	}
+
+	if err := deleted.HardDelete(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := count(scope.WithDeleted()); n != 1 {
+		t.Errorf("got %d rows after the hard delete", n)
+	}
+}
+
+func TestReadBackIgnoresScope(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var users DbTable
+	schema := NewTableSchema("users",
+		Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}},
+		Column{Name: "deleted_at", Type: "DATETIME"},
+	)
+	schema.AddIndex("name_UNIQUE", true, "name")
+	createTestTable(t, dbc, &users, schema)
+	users.SetSoftDeleteField("deleted_at")
+
+	var selected int
+	users.AddHook(AfterSelect, func(dbc *DbConnection, t *DbTable) error {
+		selected++
+		return nil
+	})
+
+	// a live row is outside of the OnlyDeleted scope, a deleted row outside of the default scope
+	users.OnlyDeleted()
+	users.SetFieldValue("name", "ann")
+
+	if err := users.DoInsert(dbc); err != nil {
+		t.Fatalf("insert with OnlyDeleted: %v", err)
+	}
+
+	users.WithoutDeleted()
+	deleted := users.newTableInstance()
+	deleted.SetFieldValue("name", "bob")
+	deleted.SetFieldValue("deleted_at", "2015-01-01 12:00:00")
+
+	if err := deleted.DoInsert(dbc); err != nil {
+		t.Fatalf("insert of a deleted row: %v", err)
+	}
+
+	if recId, _ := deleted.RecId(); recId == 0 || deleted.GetFieldValue("name") != "bob" {
+		t.Errorf("the deleted row has not been read back")
+	}
+
+	deleted.ClearFields()
+	deleted.SetFieldValue("name", "bob")
+
+	if err := deleted.DoUpsert(dbc, "name"); err != nil {
+		t.Fatalf("upsert of a deleted row: %v", err)
+	}
+
+	if selected != 0 {
+		t.Errorf("reading back written rows called AfterSelect %d times", selected)
+	}
+}
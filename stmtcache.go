@@ -0,0 +1,219 @@
+package dbop
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Counters of the prepared statement cache of a connection, see SetStatementCacheSize()
+type StatementCacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Size      int // number of statements in the cache
+	Capacity  int
+}
+
+// Returns the share of statements that were found in the cache, 0 if none have been executed
+func (s StatementCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Prepared statements keyed on their sql, the least recently used is closed when the cache is full
+type stmtCache struct {
+	mutex    sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+	stats    StatementCacheStats
+}
+
+type stmtEntry struct {
+	queryStr string
+	stmt     *sql.Stmt
+	users    int
+	evicted  bool
+}
+
+// Sets the number of prepared statements the connection keeps. Statements executed by the Do*
+// methods are then prepared once and reused, the least recently used statement is closed when
+// the cache is full. Statements executed with Exec() are not prepared. A size of 0, the
+// default, closes the cached statements and turns the cache off. Connections returned by
+// Begin() and WithContext() share the cache of the connection they were created from. Within a
+// transaction only statements already cached are used, others are executed unprepared.
+func (dbc *DbConnection) SetStatementCacheSize(size int) {
+	if dbc.stmts != nil {
+		dbc.stmts.close()
+	}
+
+	dbc.stmts = newStmtCache(size)
+
+	for _, replica := range dbc.replicas {
+		if replica.stmts != nil {
+			replica.stmts.close()
+		}
+
+		replica.stmts = newStmtCache(size)
+	}
+}
+
+// Returns a statement cache of the size or nil if the size is 0
+func newStmtCache(size int) *stmtCache {
+	if size <= 0 {
+		return nil
+	}
+
+	return &stmtCache{
+		capacity: size,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		stats:    StatementCacheStats{Capacity: size},
+	}
+}
+
+// Returns the counters of the prepared statement cache of the primary database, all 0 if the
+// cache is off
+func (dbc *DbConnection) StatementCacheStats() StatementCacheStats {
+	if dbc.stmts == nil {
+		return StatementCacheStats{}
+	}
+
+	dbc.stmts.mutex.Lock()
+	defer dbc.stmts.mutex.Unlock()
+
+	stats := dbc.stmts.stats
+	stats.Size = dbc.stmts.order.Len()
+
+	return stats
+}
+
+// Returns the cached statement of the sql, preparing it on a miss. The statement must be
+// released after it has been executed.
+func (c *stmtCache) get(ctx context.Context, db *sql.DB, queryStr string) (*stmtEntry, error) {
+	if entry := c.lookup(queryStr, true); entry != nil {
+		return entry, nil
+	}
+
+	// prepare without holding the lock, another statement may have been added meanwhile
+	stmt, err := db.PrepareContext(ctx, queryStr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if entry := c.lookup(queryStr, false); entry != nil {
+		stmt.Close()
+		return entry, nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &stmtEntry{queryStr: queryStr, stmt: stmt, users: 1}
+	c.entries[queryStr] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.capacity {
+		c.evict(c.order.Back())
+		c.stats.Evictions++
+	}
+
+	return entry, nil
+}
+
+// Returns the cached statement of the sql marked as in use or nil, counting the hit or miss
+func (c *stmtCache) lookup(queryStr string, count bool) *stmtEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[queryStr]
+
+	if !ok {
+		if count {
+			c.stats.Misses++
+		}
+		return nil
+	}
+
+	if count {
+		c.stats.Hits++
+	}
+
+	c.order.MoveToFront(element)
+
+	entry := element.Value.(*stmtEntry)
+	entry.users++
+
+	return entry
+}
+
+// Releases a statement returned by get(), closing it if it has been evicted in the meantime
+func (c *stmtCache) release(entry *stmtEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry.users--
+
+	if entry.evicted && entry.users == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// Removes a statement from the cache, it is closed once no longer in use
+func (c *stmtCache) evict(element *list.Element) {
+	entry := element.Value.(*stmtEntry)
+
+	c.order.Remove(element)
+	delete(c.entries, entry.queryStr)
+	entry.evicted = true
+
+	if entry.users == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// Closes all the statements of the cache
+func (c *stmtCache) close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for c.order.Len() != 0 {
+		c.evict(c.order.Back())
+	}
+}
+
+// Returns the prepared statement of the sql for the connection, bound to its transaction if one
+// has been started. Returns nil if the statement cache is off, or within a transaction if the
+// statement is not cached yet.
+func (dbc *DbConnection) prepared(queryStr string) (*sql.Stmt, func(), error) {
+	if dbc.stmts == nil {
+		return nil, nil, nil
+	}
+
+	cache := dbc.stmts
+
+	if dbc.tx != nil {
+		// preparing on the pool would wait for a second connection, which may never come while
+		// the transaction holds the last one
+		entry := cache.lookup(queryStr, true)
+
+		if entry == nil {
+			return nil, nil, nil
+		}
+
+		return dbc.tx.StmtContext(dbc.Context(), entry.stmt), func() { cache.release(entry) }, nil
+	}
+
+	entry, err := cache.get(dbc.Context(), dbc.connection, queryStr)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entry.stmt, func() { cache.release(entry) }, nil
+}
@@ -0,0 +1,60 @@
+package dbop
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStatementCacheSingleConnection(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var items, audit DbTable
+	createTestTable(t, dbc, &items, NewTableSchema("items", Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 20}}))
+
+	err := audit.InitSchema(AuditTableSchema("audit_log"))
+
+	if err == nil {
+		err = audit.DoCreateTable(dbc, false)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbc.SetPoolOptions(OpenOptions{MaxOpenConns: 1})
+	dbc.SetStatementCacheSize(10)
+	dbc.EnableAudit("audit_log")
+
+	// a statement prepared on the pool within the transaction would wait for the only connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn := dbc.WithContext(ctx)
+
+	for _, name := range []string{"first", "second"} {
+		items.ClearFields()
+		items.SetFieldValue("name", name)
+
+		err = items.DoInsert(conn)
+
+		if err != nil {
+			t.Fatalf("insert %s: %v", name, err)
+		}
+	}
+
+	audit.ClearFields()
+	rows, err := audit.DoSelect(conn)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 {
+		t.Errorf("got %d audit entries, want 2", len(rows))
+	}
+
+	if stats := dbc.StatementCacheStats(); stats.Misses == 0 {
+		t.Errorf("statement cache was not used, %+v", stats)
+	}
+}
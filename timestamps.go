@@ -0,0 +1,138 @@
+package dbop
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const timestampLayout = "2006-01-02 15:04:05"
+
+// Sets the clock used for the values of timestamp and version fields. Tests can set a fixed
+// clock. By default time.Now is used.
+func (dbc *DbConnection) SetClock(clock func() time.Time) {
+	dbc.clock = clock
+}
+
+// Returns the current time of the connection clock in the time zone of the connection.
+// Without a time zone offset the local time zone is used.
+func (dbc *DbConnection) Now() time.Time {
+	now := time.Now
+
+	if dbc.clock != nil {
+		now = dbc.clock
+	}
+
+	location, err := offsetLocation(dbc.timeZoneOffset)
+
+	if err != nil {
+		return now()
+	}
+
+	return now().In(location)
+}
+
+// Returns the time zone of an offset like +02:00. An empty offset is the local time zone.
+func offsetLocation(offset string) (*time.Location, error) {
+	if len(offset) == 0 {
+		return time.Local, nil
+	}
+
+	if offset[0] != '+' && offset[0] != '-' {
+		return nil, fmt.Errorf("Invalid time zone offset %s", offset)
+	}
+
+	parts := strings.Split(offset[1:], ":")
+	hours, err := strconv.Atoi(parts[0])
+
+	if err != nil || len(parts) > 2 {
+		return nil, fmt.Errorf("Invalid time zone offset %s", offset)
+	}
+
+	var minutes int
+
+	if len(parts) == 2 {
+		minutes, err = strconv.Atoi(parts[1])
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid time zone offset %s", offset)
+		}
+	}
+
+	seconds := hours*3600 + minutes*60
+
+	if offset[0] == '-' {
+		seconds = -seconds
+	}
+
+	return time.FixedZone(offset, seconds), nil
+}
+
+// Sets the fields maintained automatically with the current time of the connection.
+// createdField is set by DoInsert() and DoUpsert() when a row is inserted, updatedField
+// by every insert and update. Either can be empty. A field that has been set explicitly keeps
+// its value. The fields must be DATETIME, TIMESTAMP or DATE fields.
+// Panics if a field does not exist.
+func (t *DbTable) SetTimestampFields(createdField string, updatedField string) {
+	for _, fieldName := range []string{createdField, updatedField} {
+		if len(fieldName) != 0 && t.fieldId(fieldName) < 0 {
+			panic("Field not found")
+		}
+	}
+
+	t.createdField = createdField
+	t.updatedField = updatedField
+}
+
+// Returns the created and updated timestamp fields of the table, see SetTimestampFields()
+func (t DbTable) TimestampFields() (string, string) {
+	return t.createdField, t.updatedField
+}
+
+// Returns the current time of the connection as a value of the field type
+func timestampValue(dbc *DbConnection, fieldName string, fieldType string) (string, error) {
+	switch fieldType {
+	case "DATETIME", "TIMESTAMP":
+		return dbc.Now().Format(timestampLayout), nil
+	case "DATE":
+		return dbc.Now().Format("2006-01-02"), nil
+	}
+
+	return "", fmt.Errorf("Timestamp field %s must be a DATETIME, TIMESTAMP or DATE field", fieldName)
+}
+
+// Sets the timestamp fields that have not been set. Returns the ids of the fields it has set.
+func (t *DbTable) setTimestamps(dbc *DbConnection, fieldNames ...string) ([]int, error) {
+	var setIds []int
+
+	for _, fieldName := range fieldNames {
+		fId := t.fieldId(fieldName)
+
+		if fId < 0 || t.fieldValueSet[fId] {
+			continue
+		}
+
+		value, err := timestampValue(dbc, fieldName, t.columns[fId].Type)
+
+		if err != nil {
+			t.unsetFields(setIds)
+			return nil, err
+		}
+
+		t.fieldValue[fId] = value
+		t.fieldValueSet[fId] = true
+		setIds = append(setIds, fId)
+	}
+
+	return setIds, nil
+}
+
+// Reverts fields set automatically for a statement that failed back to their loaded value
+func (t *DbTable) unsetFields(fIds []int) {
+	for _, fId := range fIds {
+		t.fieldValue[fId] = t.fieldOrig[fId]
+		t.fieldValueSet[fId] = false
+		t.fieldExprs[fId] = nil
+	}
+}
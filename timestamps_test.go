@@ -0,0 +1,148 @@
+package dbop
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOffsetLocation(t *testing.T) {
+	tests := map[string]int{"+00:00": 0, "+02:00": 7200, "-05:30": -19800, "+3": 10800}
+
+	for offset, want := range tests {
+		location, err := offsetLocation(offset)
+
+		if err != nil {
+			t.Errorf("%s: %v", offset, err)
+			continue
+		}
+
+		if _, seconds := time.Date(2015, 1, 1, 0, 0, 0, 0, location).Zone(); seconds != want {
+			t.Errorf("%s: got offset %d, want %d", offset, seconds, want)
+		}
+	}
+
+	for _, offset := range []string{"02:00", "+a:00", "+02:b", "+01:02:03"} {
+		if _, err := offsetLocation(offset); err == nil {
+			t.Errorf("%s: no error", offset)
+		}
+	}
+}
+
+// Returns true if the field holds the time. The sqlite driver scans DATETIME and DATE columns
+// into a time.Time, its string has the zone appended.
+func hasTime(tbl DbTable, fieldName string, want string) bool {
+	return strings.HasPrefix(tbl.GetFieldValue(fieldName), want)
+}
+
+func TestTimestampFields(t *testing.T) {
+	dbc := openTestDB(t)
+	dbc.timeZoneOffset = "+02:00"
+
+	now := time.Date(2015, 1, 1, 12, 0, 0, 0, time.UTC)
+	dbc.SetClock(func() time.Time { return now })
+
+	var users DbTable
+	schema := NewTableSchema("users",
+		Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}},
+		Column{Name: "registered", Type: "DATETIME"},
+		Column{Name: "updated", Type: "DATETIME"},
+	)
+	schema.AddIndex("name_UNIQUE", true, "name")
+	createTestTable(t, dbc, &users, schema)
+	users.SetTimestampFields("registered", "updated")
+
+	users.SetFieldValue("name", "ann")
+
+	if err := users.DoInsert(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasTime(users, "registered", "2015-01-01 14:00:00") || !hasTime(users, "updated", "2015-01-01 14:00:00") {
+		t.Errorf("after the insert, got %s, %s", users.GetFieldValue("registered"), users.GetFieldValue("updated"))
+	}
+
+	now = now.Add(time.Hour)
+	users.SetFieldValue("name", "anna")
+
+	if err := users.DoUpdate(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasTime(users, "registered", "2015-01-01 14:00:00") || !hasTime(users, "updated", "2015-01-01 15:00:00") {
+		t.Errorf("after the update, got %s, %s", users.GetFieldValue("registered"), users.GetFieldValue("updated"))
+	}
+
+	// an upsert updating the row keeps its created timestamp
+	now = now.Add(time.Hour)
+	upsert := users.newTableInstance()
+	upsert.SetFieldValue("name", "anna")
+
+	if err := upsert.DoUpsert(dbc, "name"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasTime(upsert, "registered", "2015-01-01 14:00:00") || !hasTime(upsert, "updated", "2015-01-01 16:00:00") {
+		t.Errorf("after the upsert, got %s, %s", upsert.GetFieldValue("registered"), upsert.GetFieldValue("updated"))
+	}
+
+	// explicitly set values are kept
+	explicit := users.newTableInstance()
+	explicit.SetFieldValue("name", "bob")
+	explicit.SetFieldValue("registered", "2010-05-05 10:00:00")
+
+	if err := explicit.DoInsert(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasTime(explicit, "registered", "2010-05-05 10:00:00") || !hasTime(explicit, "updated", "2015-01-01 16:00:00") {
+		t.Errorf("with an explicit value, got %s, %s", explicit.GetFieldValue("registered"), explicit.GetFieldValue("updated"))
+	}
+
+	now = now.Add(time.Hour)
+	bulk := users.newTableInstance()
+	bulk.SetFieldValue("name", "carl")
+
+	if rows, err := bulk.DoUpdateWhere(dbc, Where("name", "=", "bob")); err != nil || rows != 1 {
+		t.Fatalf("DoUpdateWhere updated %d rows, %v", rows, err)
+	}
+
+	explicit.ClearFields()
+	explicit.SetFieldValue("name", "carl")
+
+	if err := explicit.DoSelectFirstonly(dbc); err != nil || !hasTime(explicit, "updated", "2015-01-01 17:00:00") {
+		t.Errorf("after DoUpdateWhere, got %s, %v", explicit.GetFieldValue("updated"), err)
+	}
+}
+
+func TestTimestampFieldTypes(t *testing.T) {
+	dbc := openTestDB(t)
+	dbc.SetClock(func() time.Time { return time.Date(2015, 1, 1, 12, 0, 0, 0, time.UTC) })
+	dbc.timeZoneOffset = "+00:00"
+
+	var events DbTable
+	createTestTable(t, dbc, &events, NewTableSchema("events",
+		Column{Name: "day", Type: "DATE"},
+		Column{Name: "note", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}},
+	))
+	events.SetTimestampFields("day", "")
+
+	if err := events.DoInsert(dbc); err != nil || !hasTime(events, "day", "2015-01-01") {
+		t.Errorf("got %s, %v", events.GetFieldValue("day"), err)
+	}
+
+	wrongType := events.newTableInstance()
+	wrongType.SetTimestampFields("", "note")
+
+	if err := wrongType.DoInsert(dbc); err == nil {
+		t.Errorf("a VARCHAR timestamp field gives no error")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("an unknown timestamp field does not panic")
+		}
+	}()
+
+	events.SetTimestampFields("created", "")
+}
@@ -0,0 +1,165 @@
+package dbop
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Returns the connection string with the session time zone of the offset for the drivers that
+// take it from their connection string. Returns false if the driver does not. A time zone
+// already set in the connection string is kept.
+func timeZoneDSN(driverName string, connectionStr string, offset string) (string, bool, error) {
+	switch driverName {
+	case "mysql":
+		// user:password@tcp(host)/database?param=value, the parameters follow the last /
+		paramsStart := strings.LastIndex(connectionStr, "/")
+		separator := "?"
+
+		if pos := strings.Index(connectionStr[paramsStart+1:], "?"); pos >= 0 {
+			params, err := url.ParseQuery(connectionStr[paramsStart+pos+2:])
+
+			if err != nil {
+				return "", false, err
+			}
+
+			if _, ok := params["time_zone"]; ok {
+				return connectionStr, true, nil
+			}
+
+			separator = "&"
+		}
+
+		// any other parameter is set as a system variable of each connection
+		return connectionStr + separator + "time_zone=" + url.QueryEscape("'"+offset+"'"), true, nil
+
+	case "postgres", "pgx", "pq":
+		// postgres reads +02:00 as a POSIX time zone with the sign the other way round
+		zone := "UTC+" + offset[1:]
+
+		if offset[0] == '+' {
+			zone = "UTC-" + offset[1:]
+		}
+
+		if strings.Contains(connectionStr, "://") {
+			u, err := url.Parse(connectionStr)
+
+			if err != nil {
+				return "", false, err
+			}
+
+			params := u.Query()
+
+			if _, ok := params["timezone"]; !ok {
+				params.Set("timezone", zone)
+				u.RawQuery = params.Encode()
+			}
+
+			return u.String(), true, nil
+		}
+
+		if strings.Contains(connectionStr, "timezone=") {
+			return connectionStr, true, nil
+		}
+
+		return strings.TrimSpace(connectionStr + " timezone='" + zone + "'"), true, nil
+	}
+
+	return connectionStr, false, nil
+}
+
+// Opens the connections of a driver and runs a statement on each of them, used to set the
+// session time zone of drivers that can't take it from the connection string
+type sessionConnector struct {
+	driver        driver.Driver
+	connectionStr string
+	sessionStr    string
+}
+
+func (c sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.connectionStr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = execSession(ctx, conn, c.sessionStr)
+
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Time zone failed: %v", err)
+	}
+
+	return conn, nil
+}
+
+func (c sessionConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+func execSession(ctx context.Context, conn driver.Conn, queryStr string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, queryStr, nil)
+
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+
+	stmt, err := conn.Prepare(queryStr)
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	if stmtExecer, ok := stmt.(driver.StmtExecContext); ok {
+		_, err = stmtExecer.ExecContext(ctx, nil)
+		return err
+	}
+
+	// drivers without StmtExecContext only have the deprecated Exec
+	_, err = stmt.Exec(nil)
+
+	return err
+}
+
+// Opens a database with the session time zone of the offset set on each of its connections
+func openTimeZoneDB(driverName string, connectionStr string, dialect Dialect, offset string) (*sql.DB, error) {
+	_, err := offsetLocation(offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	timeZoneStr := dialect.TimeZoneStr(offset)
+
+	if len(timeZoneStr) == 0 {
+		return sql.Open(driverName, connectionStr)
+	}
+
+	dsn, ok, err := timeZoneDSN(driverName, connectionStr, offset)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		return sql.Open(driverName, dsn)
+	}
+
+	db, err := sql.Open(driverName, connectionStr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	drv := db.Driver()
+	db.Close()
+
+	return sql.OpenDB(sessionConnector{driver: drv, connectionStr: connectionStr, sessionStr: timeZoneStr}), nil
+}
@@ -0,0 +1,90 @@
+package dbop
+
+import (
+	"fmt"
+)
+
+// Starts a transaction. Returns a connection bound to the transaction, all db operations
+// executed with it are part of the transaction until Commit() or Rollback() is called.
+// The original connection can still be used outside of the transaction.
+func (dbc *DbConnection) Begin() (*DbConnection, error) {
+	if dbc.tx != nil {
+		return nil, fmt.Errorf("Transaction has already been started")
+	}
+
+	tx, err := dbc.connection.BeginTx(dbc.Context(), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dbc.debugPrint("BEGIN", nil)
+
+	txc := *dbc
+	txc.tx = tx
+	txc.txTables = make(map[string]bool)
+
+	return &txc, nil
+}
+
+// Commits the transaction of a connection returned by Begin()
+func (dbc *DbConnection) Commit() error {
+	if dbc.tx == nil {
+		return fmt.Errorf("No transaction has been started")
+	}
+
+	dbc.debugPrint("COMMIT", nil)
+	defer dbc.invalidateTxTables()
+
+	return dbc.tx.Commit()
+}
+
+// Rolls back the transaction of a connection returned by Begin()
+func (dbc *DbConnection) Rollback() error {
+	if dbc.tx == nil {
+		return fmt.Errorf("No transaction has been started")
+	}
+
+	dbc.debugPrint("ROLLBACK", nil)
+	defer dbc.invalidateTxTables()
+
+	return dbc.tx.Rollback()
+}
+
+// Returns true if the connection is bound to a transaction
+func (dbc *DbConnection) InTransaction() bool {
+	return dbc.tx != nil
+}
+
+// Runs fn within a transaction. The transaction is committed if fn returns nil and rolled back
+// if fn returns an error or panics. With a retry policy, the transaction is run again if it
+// fails with a transient error, see SetRetryPolicy().
+func (dbc *DbConnection) Transaction(fn func(tx *DbConnection) error) error {
+	return dbc.retry(true, func() error {
+		return dbc.transaction(fn)
+	})
+}
+
+func (dbc *DbConnection) transaction(fn func(tx *DbConnection) error) error {
+	tx, err := dbc.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	err = fn(tx)
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,244 @@
+package dbop
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// A validation rule of a field, created with Required(), MaxLength(), Range(), Pattern(),
+// OneOf(), DateFormat() or CustomRule() and added with AddFieldRules()
+type Rule struct {
+	name     string
+	required bool
+	check    func(value string) string
+}
+
+// Returns the name of the rule, e.g. max_length
+func (r Rule) Name() string {
+	return r.name
+}
+
+// A field value that does not pass a rule
+type Violation struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+// Returned by Validate() and the operations validating the field values, lists every violation
+type ValidationError struct {
+	Table      string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+
+	for i, v := range e.Violations {
+		messages[i] = v.Field + ": " + v.Message
+	}
+
+	return "Validation of " + e.Table + " failed: " + strings.Join(messages, "; ")
+}
+
+// The field must have a non empty value. For inserts the field must be set, for updates it
+// can't be set to an empty value.
+func Required() Rule {
+	return Rule{name: "required", required: true}
+}
+
+// The value can't be longer than max characters
+func MaxLength(max int) Rule {
+	return Rule{name: "max_length", check: func(value string) string {
+		if utf8.RuneCountInString(value) > max {
+			return fmt.Sprintf("must be at most %v characters long", max)
+		}
+		return ""
+	}}
+}
+
+// The value must be a number between min and max, both included
+func Range(min float64, max float64) Rule {
+	return Rule{name: "range", check: func(value string) string {
+		number, err := strconv.ParseFloat(value, 64)
+
+		if err != nil {
+			return "must be a number"
+		}
+
+		if number < min || number > max {
+			return fmt.Sprintf("must be between %v and %v", min, max)
+		}
+		return ""
+	}}
+}
+
+// The value must match the regular expression. Panics if the expression can't be compiled.
+func Pattern(expr string) Rule {
+	re := regexp.MustCompile(expr)
+
+	return Rule{name: "pattern", check: func(value string) string {
+		if !re.MatchString(value) {
+			return "must match " + expr
+		}
+		return ""
+	}}
+}
+
+// The value must be one of the given values
+func OneOf(values ...string) Rule {
+	return Rule{name: "one_of", check: func(value string) string {
+		if !inList(value, values) {
+			return "must be one of " + strings.Join(values, ", ")
+		}
+		return ""
+	}}
+}
+
+// The value must be a date or time in the layout of the time package, e.g. 2006-01-02
+func DateFormat(layout string) Rule {
+	return Rule{name: "date_format", check: func(value string) string {
+		_, err := time.Parse(layout, value)
+
+		if err != nil {
+			return "must be a date in the format " + layout
+		}
+		return ""
+	}}
+}
+
+// A rule checked by a function returning an error for invalid values
+func CustomRule(name string, check func(value string) error) Rule {
+	return Rule{name: name, check: func(value string) string {
+		err := check(value)
+
+		if err != nil {
+			return err.Error()
+		}
+		return ""
+	}}
+}
+
+// Adds validation rules to a field. Rules other than Required() are only checked for non empty
+// values. Besides the rules, integer and decimal fields must have numeric values and CHAR and
+// VARCHAR fields can't be longer than their size set with SetFieldAttr(). Returns false if
+// the field does not exist.
+func (t *DbTable) AddFieldRules(fieldName string, rules ...Rule) bool {
+	fId := t.fieldId(fieldName)
+
+	if fId < 0 {
+		return false
+	}
+
+	t.fieldRules[fId] = append(t.fieldRules[fId], rules...)
+
+	return true
+}
+
+// Checks the set field values against the rules of the fields and their types. Without a
+// selected record the values are checked as for an insert, otherwise as for an update.
+// DoInsert(), DoUpsert(), DoUpdate() and DoUpdateWhere() validate the values before executing.
+// Returns a *ValidationError listing all violations or nil.
+func (t DbTable) Validate() error {
+	return t.validate(!t.loaded)
+}
+
+func (t DbTable) validate(insert bool) error {
+	var violations []Violation
+
+	for fId, fieldName := range t.GetFieldNameList() {
+		if t.fieldExprs[fId] != nil {
+			continue
+		}
+
+		value := t.fieldValue[fId]
+		isSet := t.fieldValueSet[fId]
+
+		for _, rule := range t.fieldRules[fId] {
+			if rule.required && ((insert && !isSet) || (isSet && len(value) == 0)) {
+				violations = append(violations, Violation{Field: fieldName, Rule: rule.name, Message: "is required"})
+			}
+		}
+
+		if !isSet || len(value) == 0 {
+			continue
+		}
+
+		message := typeViolation(value, t.columns[fId].Type, t.columns[fId].FieldAttr)
+
+		if len(message) != 0 {
+			violations = append(violations, Violation{Field: fieldName, Rule: "type", Message: message})
+			continue
+		}
+
+		for _, rule := range t.fieldRules[fId] {
+			if rule.check == nil {
+				continue
+			}
+
+			message := rule.check(value)
+
+			if len(message) != 0 {
+				violations = append(violations, Violation{Field: fieldName, Rule: rule.name, Message: message})
+			}
+		}
+	}
+
+	if len(violations) != 0 {
+		return &ValidationError{Table: t.tableName, Violations: violations}
+	}
+
+	return nil
+}
+
+// Returns the reason the value can't be stored in a field of the type or an empty string
+func typeViolation(value string, fieldType string, attr FieldAttr) string {
+	switch fieldType {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "SERIAL", "YEAR":
+		bits := integerBits(fieldType)
+
+		if attr.Unsigned || fieldType == "SERIAL" {
+			_, err := strconv.ParseUint(value, 10, bits)
+			if err != nil {
+				return fmt.Sprintf("must be a positive integer of at most %v bits", bits)
+			}
+		} else {
+			_, err := strconv.ParseInt(value, 10, bits)
+			if err != nil {
+				return fmt.Sprintf("must be an integer of at most %v bits", bits)
+			}
+		}
+
+	case "DECIMAL", "DEC", "FLOAT", "DOUBLE":
+		_, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "must be a number"
+		}
+
+	case "CHAR", "VARCHAR":
+		if attr.Size > 0 && utf8.RuneCountInString(value) > attr.Size {
+			return fmt.Sprintf("must be at most %v characters long", attr.Size)
+		}
+	}
+
+	return ""
+}
+
+func integerBits(fieldType string) int {
+	switch fieldType {
+	case "TINYINT":
+		return 8
+	case "SMALLINT", "YEAR":
+		return 16
+	case "MEDIUMINT":
+		return 24
+	case "INT", "INTEGER":
+		return 32
+	}
+
+	return 64
+}
@@ -0,0 +1,106 @@
+package dbop
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	even := CustomRule("even", func(value string) error {
+		if len(value)%2 != 0 {
+			return fmt.Errorf("must have an even length")
+		}
+		return nil
+	})
+
+	tests := []struct {
+		rule  Rule
+		value string
+		valid bool
+	}{
+		{MaxLength(3), "abc", true},
+		{MaxLength(3), "äöü", true},
+		{MaxLength(3), "abcd", false},
+		{Range(1, 5), "1", true},
+		{Range(1, 5), "4.5", true},
+		{Range(1, 5), "5.1", false},
+		{Range(1, 5), "x", false},
+		{Pattern(`^[a-z]+@[a-z]+$`), "ann@example", true},
+		{Pattern(`^[a-z]+@[a-z]+$`), "ann", false},
+		{OneOf("a", "b"), "b", true},
+		{OneOf("a", "b"), "c", false},
+		{DateFormat("2006-01-02"), "2024-02-29", true},
+		{DateFormat("2006-01-02"), "2023-02-29", false},
+		{even, "ab", true},
+		{even, "abc", false},
+	}
+
+	for _, test := range tests {
+		message := test.rule.check(test.value)
+
+		if (len(message) == 0) != test.valid {
+			t.Errorf("%s(%s): got %q", test.rule.Name(), test.value, message)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var tbl DbTable
+
+	tbl.InitTable("users", []string{"name", "role", "age", "code"}, []string{"VARCHAR", "VARCHAR", "TINYINT", "INT"}, [2]bool{true, true})
+	tbl.SetFieldAttr("name", FieldAttr{Size: 5})
+	tbl.SetFieldAttr("age", FieldAttr{Unsigned: true})
+	tbl.AddFieldRules("name", Required())
+	tbl.AddFieldRules("role", Required(), OneOf("admin", "user"))
+	tbl.AddFieldRules("code", Range(10, 20))
+
+	if tbl.AddFieldRules("email", Required()) {
+		t.Errorf("rules added to an unknown field")
+	}
+
+	tbl.SetFieldValue("name", "annabel")
+	tbl.SetFieldValue("age", "-1")
+	tbl.SetFieldValue("code", "5")
+
+	var validationErr *ValidationError
+
+	if err := tbl.Validate(); !errors.As(err, &validationErr) {
+		t.Fatalf("got %v, want a *ValidationError", err)
+	}
+
+	want := []Violation{
+		{Field: "name", Rule: "type", Message: "must be at most 5 characters long"},
+		{Field: "role", Rule: "required", Message: "is required"},
+		{Field: "age", Rule: "type", Message: "must be a positive integer of at most 8 bits"},
+		{Field: "code", Rule: "range", Message: "must be between 10 and 20"},
+	}
+
+	if !reflect.DeepEqual(validationErr.Violations, want) {
+		t.Errorf("got %+v, want %+v", validationErr.Violations, want)
+	}
+
+	tbl.SetFieldValue("name", "ann")
+	tbl.SetFieldValue("role", "user")
+	tbl.SetFieldValue("age", "255")
+	tbl.SetFieldExpr("code", "code + ?", 1)
+
+	if err := tbl.Validate(); err != nil {
+		t.Errorf("got %v for valid values", err)
+	}
+
+	// an update only checks the fields that are set
+	tbl.ClearFields()
+	tbl.SetFieldValue("age", "30")
+
+	if err := tbl.validate(false); err != nil {
+		t.Errorf("got %v for an update", err)
+	}
+
+	tbl.SetFieldValue("role", "")
+
+	if err := tbl.validate(false); err == nil {
+		t.Errorf("no error for a required field set to an empty value")
+	}
+}
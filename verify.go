@@ -0,0 +1,277 @@
+package dbop
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Describes a column of a database table as reported by INFORMATION_SCHEMA
+type ColumnInfo struct {
+	Name       string
+	Type       string // upper case data type without size, e.g. VARCHAR
+	ColumnType string // full column type as reported by the database, e.g. varchar(45) unsigned
+	Position   int
+	Nullable   bool
+	Default    string
+	HasDefault bool
+	Key        string // PRI, UNI or MUL
+	Extra      string // e.g. auto_increment
+	Size       int
+	Scale      int
+	Unsigned   bool
+	Comment    string
+}
+
+// Returns the columns of a table in the connected database ordered by their position, as
+// reported by the columns query of the dialect.
+// Returns an empty slice if the table does not exist.
+func (dbc *DbConnection) TableColumns(tableName string) ([]ColumnInfo, error) {
+	var columns []ColumnInfo
+
+	rows, err := dbc.query(dbc.dialect.ColumnsQuery(), []interface{}{tableName})
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var column ColumnInfo
+		var isNullable string
+		var defaultValue sql.NullString
+		var charLength, precision, scale sql.NullInt64
+
+		err = rows.Scan(&column.Name, &column.Type, &column.ColumnType, &column.Position, &isNullable,
+			&defaultValue, &column.Key, &column.Extra, &charLength, &precision, &scale, &column.Comment)
+
+		if err != nil {
+			return nil, err
+		}
+
+		column.Type = strings.ToUpper(column.Type)
+		column.Nullable = isNullable == "YES"
+		column.Default = defaultValue.String
+		column.HasDefault = defaultValue.Valid
+		column.Unsigned = strings.Contains(strings.ToLower(column.ColumnType), "unsigned")
+
+		switch column.Type {
+		case "CHAR", "VARCHAR", "BINARY", "VARBINARY":
+			column.Size = int(charLength.Int64)
+		case "DECIMAL":
+			column.Size = int(precision.Int64)
+			column.Scale = int(scale.Int64)
+		}
+
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+// Returns the names of all the base tables in the connected database
+func (dbc *DbConnection) TableNames() ([]string, error) {
+	var names []string
+
+	rows, err := dbc.query(dbc.dialect.TablesQuery(), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+
+		err = rows.Scan(&name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// Describes a field whose type in the table definition differs from the database
+type TypeMismatch struct {
+	FieldName    string
+	DefinedType  string
+	DatabaseType string
+}
+
+// Describes all the differences found between a table definition and the database table.
+// Returned as an error by Verify().
+type SchemaDrift struct {
+	TableName      string
+	Missing        bool     // the table does not exist in the database
+	MissingColumns []string // defined, but not in the database
+	ExtraColumns   []string // in the database, but not defined
+	TypeMismatches []TypeMismatch
+	OrderMismatch  bool
+	DefinedOrder   []string
+	DatabaseOrder  []string
+	RecIdProblems  []string
+}
+
+// Returns true if no differences have been found
+func (d *SchemaDrift) IsEmpty() bool {
+	return !d.Missing && len(d.MissingColumns) == 0 && len(d.ExtraColumns) == 0 &&
+		len(d.TypeMismatches) == 0 && !d.OrderMismatch && len(d.RecIdProblems) == 0
+}
+
+func (d *SchemaDrift) Error() string {
+	var problems []string
+
+	if d.Missing {
+		return "Table " + d.TableName + " does not exist"
+	}
+
+	if len(d.MissingColumns) != 0 {
+		problems = append(problems, "missing columns "+strings.Join(d.MissingColumns, ", "))
+	}
+
+	if len(d.ExtraColumns) != 0 {
+		problems = append(problems, "extra columns "+strings.Join(d.ExtraColumns, ", "))
+	}
+
+	for _, mismatch := range d.TypeMismatches {
+		problems = append(problems, fmt.Sprintf("%s is %s in the database, defined as %s", mismatch.FieldName, mismatch.DatabaseType, mismatch.DefinedType))
+	}
+
+	if d.OrderMismatch {
+		problems = append(problems, "column order is ("+strings.Join(d.DatabaseOrder, ", ")+"), defined as ("+strings.Join(d.DefinedOrder, ", ")+")")
+	}
+
+	problems = append(problems, d.RecIdProblems...)
+
+	return "Table " + d.TableName + " does not match its definition: " + strings.Join(problems, "; ")
+}
+
+// Compares the table definition with the table in the database and returns a *SchemaDrift error
+// describing missing and extra columns, type differences, a different column order and recid
+// problems. Any of those would make the positional scanning of selects put values in the wrong
+// fields. Returns nil if the definition matches the database.
+func (t DbTable) Verify(dbc *DbConnection) error {
+	drift := &SchemaDrift{TableName: t.tableName}
+
+	columns, err := dbc.TableColumns(t.tableName)
+
+	if err != nil {
+		return err
+	}
+
+	if len(columns) == 0 {
+		drift.Missing = true
+		return drift
+	}
+
+	dbColumns := make(map[string]ColumnInfo)
+	for _, column := range columns {
+		dbColumns[column.Name] = column
+	}
+
+	if t.recid.Exists {
+		drift.DefinedOrder = append(drift.DefinedOrder, "recid")
+	}
+	drift.DefinedOrder = append(drift.DefinedOrder, t.GetFieldNameList()...)
+
+	for _, column := range columns {
+		drift.DatabaseOrder = append(drift.DatabaseOrder, column.Name)
+	}
+
+	recidColumn, hasRecId := dbColumns["recid"]
+
+	switch {
+	case t.recid.Exists && !hasRecId:
+		drift.RecIdProblems = append(drift.RecIdProblems, "recid is defined, but the column does not exist")
+
+	case !t.recid.Exists && hasRecId:
+		drift.RecIdProblems = append(drift.RecIdProblems, "recid column exists, but the definition does not use recid")
+
+	case t.recid.Exists:
+		if recidColumn.Position != 1 {
+			drift.RecIdProblems = append(drift.RecIdProblems, "recid must be the first column")
+		}
+
+		switch recidColumn.Type {
+		case "BIGINT", "INT", "INTEGER", "MEDIUMINT", "SMALLINT", "TINYINT":
+		default:
+			drift.RecIdProblems = append(drift.RecIdProblems, "recid must be an integer column, it is "+recidColumn.Type)
+		}
+
+		autoInc := strings.Contains(strings.ToLower(recidColumn.Extra), "auto_increment")
+		if autoInc != t.recid.AutoInc {
+			drift.RecIdProblems = append(drift.RecIdProblems, fmt.Sprintf("recid AUTO_INCREMENT is %v in the database, defined as %v", autoInc, t.recid.AutoInc))
+		}
+	}
+
+	for fId, fieldName := range t.GetFieldNameList() {
+		column, ok := dbColumns[fieldName]
+
+		if !ok {
+			drift.MissingColumns = append(drift.MissingColumns, fieldName)
+			continue
+		}
+
+		if dbc.dialect.NormalizeType(t.columns[fId].Type) != column.Type {
+			drift.TypeMismatches = append(drift.TypeMismatches, TypeMismatch{FieldName: fieldName, DefinedType: t.columns[fId].Type, DatabaseType: column.Type})
+		}
+	}
+
+	for _, column := range columns {
+		if column.Name == "recid" {
+			continue
+		}
+
+		if t.fieldId(column.Name) < 0 {
+			drift.ExtraColumns = append(drift.ExtraColumns, column.Name)
+		}
+	}
+
+	// compare the order of the columns that exist on both sides
+	var definedCommon, databaseCommon []string
+
+	for _, name := range drift.DefinedOrder {
+		if _, ok := dbColumns[name]; ok {
+			definedCommon = append(definedCommon, name)
+		}
+	}
+
+	for _, name := range drift.DatabaseOrder {
+		if name == "recid" && t.recid.Exists || t.fieldId(name) >= 0 {
+			databaseCommon = append(databaseCommon, name)
+		}
+	}
+
+	for i := range definedCommon {
+		if definedCommon[i] != databaseCommon[i] {
+			drift.OrderMismatch = true
+			break
+		}
+	}
+
+	if drift.IsEmpty() {
+		return nil
+	}
+
+	return drift
+}
+
+// Verifies a list of table definitions, meant to be called at startup to fail fast.
+// Returns the first *SchemaDrift or query error encountered.
+func VerifyTables(dbc *DbConnection, tables ...DbTable) error {
+	for _, tbl := range tables {
+		err := tbl.Verify(dbc)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
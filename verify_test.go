@@ -0,0 +1,111 @@
+package dbop
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var users DbTable
+	createTestTable(t, dbc, &users, NewTableSchema("users",
+		Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}},
+		Column{Name: "rating", Type: "INT"},
+		Column{Name: "created", Type: "DATETIME"},
+	))
+
+	if err := users.Verify(dbc); err != nil {
+		t.Fatalf("created table does not match: %v", err)
+	}
+
+	if names, err := dbc.TableNames(); err != nil || !reflect.DeepEqual(names, []string{"users"}) {
+		t.Errorf("got tables %v, %v", names, err)
+	}
+
+	recid := Column{Name: "recid", Type: "BIGINT", AutoIncrement: true, PrimaryKey: true}
+	name := Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}}
+	rating := Column{Name: "rating", Type: "INT"}
+	created := Column{Name: "created", Type: "DATETIME"}
+
+	tests := []struct {
+		name   string
+		schema TableSchema
+		want   SchemaDrift
+	}{
+		{"missing table", NewTableSchema("roles", recid, name), SchemaDrift{Missing: true}},
+		{"missing and extra columns", NewTableSchema("users", recid, name, rating, Column{Name: "email", Type: "VARCHAR"}),
+			SchemaDrift{MissingColumns: []string{"email"}, ExtraColumns: []string{"created"}}},
+		{"type mismatch", NewTableSchema("users", recid, name, Column{Name: "rating", Type: "DECIMAL"}, created),
+			SchemaDrift{TypeMismatches: []TypeMismatch{{FieldName: "rating", DefinedType: "DECIMAL", DatabaseType: "INT"}}}},
+		{"order mismatch", NewTableSchema("users", recid, rating, name, created),
+			SchemaDrift{OrderMismatch: true}},
+		{"no recid", NewTableSchema("users", name, rating, created),
+			SchemaDrift{RecIdProblems: []string{"recid column exists, but the definition does not use recid"}}},
+	}
+
+	for _, test := range tests {
+		var tbl DbTable
+
+		if err := tbl.InitSchema(test.schema); err != nil {
+			t.Fatal(err)
+		}
+
+		err := VerifyTables(dbc, users, tbl)
+
+		var drift *SchemaDrift
+
+		if !errors.As(err, &drift) {
+			t.Errorf("%s: got %v, want a *SchemaDrift", test.name, err)
+			continue
+		}
+
+		got := SchemaDrift{Missing: drift.Missing, MissingColumns: drift.MissingColumns, ExtraColumns: drift.ExtraColumns,
+			TypeMismatches: drift.TypeMismatches, OrderMismatch: drift.OrderMismatch, RecIdProblems: drift.RecIdProblems}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestVerifyRecIdAutoIncrement(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var plain DbTable
+	err := plain.InitSchema(NewTableSchema("plain",
+		Column{Name: "recid", Type: "BIGINT", PrimaryKey: true},
+		Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}},
+	))
+
+	if err == nil {
+		err = plain.DoCreateTable(dbc, false)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := plain.Verify(dbc); err != nil {
+		t.Errorf("recid without auto increment does not match: %v", err)
+	}
+
+	var autoInc DbTable
+	createTestTable(t, dbc, &autoInc, NewTableSchema("auto_inc", Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 45}}))
+
+	// the definitions swapped, the recid of each differs from its table
+	for _, test := range []struct {
+		tbl       DbTable
+		tableName string
+	}{{plain, "auto_inc"}, {autoInc, "plain"}} {
+		tbl := test.tbl
+		tbl.tableName = test.tableName
+
+		var drift *SchemaDrift
+
+		if err := tbl.Verify(dbc); !errors.As(err, &drift) || len(drift.RecIdProblems) != 1 {
+			t.Errorf("%s: got %v, want a recid AUTO_INCREMENT problem", test.tableName, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package dbop
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Returned by DoUpdate() when the version field of the record has been changed in the db
+// since the record was selected, meaning someone else has updated it in the meantime
+var ErrStaleRecord = errors.New("Record has been changed since it was selected")
+
+// Sets the field used for optimistic locking. DoUpdate() only updates the record if the
+// version field still has the value it had when the record was selected, and changes it with
+// every update. Integer version fields are incremented, DATETIME and TIMESTAMP version fields
+// are set to the current time with second precision. DoInsert() and DoUpsert() set the initial
+// version if the field has not been set. DoUpdateWhere() and the update of an existing row by
+// DoUpsert() change the version of the rows without checking it. The version field can't be set
+// for DoUpdate() and DoUpdateWhere().
+// Panics if the field does not exist.
+func (t *DbTable) SetVersionField(fieldName string) {
+	if t.fieldId(fieldName) < 0 {
+		panic("Field not found")
+	}
+
+	t.versionField = fieldName
+}
+
+// Returns the version field of the table or an empty string if optimistic locking is not used
+func (t DbTable) VersionField() string {
+	return t.versionField
+}
+
+func isVersionTimeType(fieldType string) bool {
+	return fieldType == "DATETIME" || fieldType == "TIMESTAMP"
+}
+
+// Returns the version value of a new record
+func (t DbTable) initialVersion(dbc *DbConnection) (string, error) {
+	fieldType := t.GetFieldType(t.versionField)
+
+	switch {
+	case isVersionTimeType(fieldType):
+		return dbc.Now().Format(timestampLayout), nil
+	case isIntegerType(fieldType):
+		return "1", nil
+	}
+
+	return "", fmt.Errorf("Version field %s must be an integer, DATETIME or TIMESTAMP field", t.versionField)
+}
+
+// Returns the version value the selected record had in the db, and the version value replacing it
+func (t DbTable) nextVersion(dbc *DbConnection) (string, string, error) {
+	fId := t.fieldId(t.versionField)
+	current := t.fieldOrig[fId]
+
+	if isVersionTimeType(t.columns[fId].Type) {
+		next := dbc.Now()
+
+		if len(current) >= len(timestampLayout) {
+			current = current[:len(timestampLayout)]
+			currentTime, err := time.ParseInLocation(timestampLayout, current, next.Location())
+
+			if err != nil {
+				return "", "", fmt.Errorf("Version field %s: %v", t.versionField, err)
+			}
+
+			if !next.After(currentTime.Add(time.Second)) {
+				next = currentTime.Add(time.Second)
+			}
+		}
+
+		return current, next.Format(timestampLayout), nil
+	}
+
+	if !isIntegerType(t.columns[fId].Type) {
+		return "", "", fmt.Errorf("Version field %s must be an integer, DATETIME or TIMESTAMP field", t.versionField)
+	}
+
+	if len(current) == 0 {
+		return "", "1", nil
+	}
+
+	version, err := strconv.ParseInt(current, 10, 64)
+
+	if err != nil {
+		return "", "", fmt.Errorf("Version field %s: %v", t.versionField, err)
+	}
+
+	return current, strconv.FormatInt(version+1, 10), nil
+}
+
+// Sets the version field for DoUpdateWhere(), integer versions are incremented by the db for
+// each row and time versions are set to the current time
+func (t *DbTable) setWhereVersion(dbc *DbConnection, versionId int) error {
+	fieldType := t.columns[versionId].Type
+
+	switch {
+	case isVersionTimeType(fieldType):
+		t.fieldValue[versionId] = dbc.Now().Format(timestampLayout)
+		t.fieldValueSet[versionId] = true
+		return nil
+	case isIntegerType(fieldType):
+		t.setFieldExpr(t.versionField, &fieldExpr{operator: "+", value: "1"})
+		return nil
+	}
+
+	return fmt.Errorf("Version field %s must be an integer, DATETIME or TIMESTAMP field", t.versionField)
+}
+
+// Returns the assignment incrementing an integer version field in the update branch of an
+// upsert, or an empty string if the version is set from the inserted value
+func (t DbTable) upsertVersionStr(dbc *DbConnection) (string, error) {
+	if len(t.versionField) == 0 || !isIntegerType(t.GetFieldType(t.versionField)) {
+		return "", nil
+	}
+
+	tableStr, err := quoteIdent(dbc.dialect, t.tableName)
+
+	if err != nil {
+		return "", err
+	}
+
+	fieldStr, err := quoteIdent(dbc.dialect, t.versionField)
+
+	if err != nil {
+		return "", err
+	}
+
+	// qualified, postgres would find the field ambiguous with the one of the excluded row
+	return fieldStr + " = COALESCE(" + tableStr + "." + fieldStr + ", 0) + 1", nil
+}
+
+func isIntegerType(fieldType string) bool {
+	switch fieldType {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "SERIAL":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+package dbop
+
+import "testing"
+
+func TestVersionField(t *testing.T) {
+	dbc := openTestDB(t)
+
+	var items DbTable
+	schema := NewTableSchema("items",
+		Column{Name: "code", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 10}},
+		Column{Name: "name", Type: "VARCHAR", FieldAttr: FieldAttr{Size: 20}},
+		Column{Name: "version", Type: "INT"},
+	)
+	schema.AddIndex("code_idx", true, "code")
+	createTestTable(t, dbc, &items, schema)
+	items.SetVersionField("version")
+
+	version := func() string {
+		tbl := items.newTableInstance()
+		tbl.SetFieldValue("code", "a")
+
+		if err := tbl.DoSelectFirstonly(dbc); err != nil {
+			t.Fatal(err)
+		}
+
+		return tbl.GetFieldValue("version")
+	}
+
+	items.SetFieldValue("code", "a")
+	items.SetFieldValue("name", "first")
+
+	if err := items.DoInsert(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := version(); got != "1" {
+		t.Fatalf("got version %s after the insert", got)
+	}
+
+	other := items.newTableInstance()
+	other.SetRecId(items.recid.Value)
+
+	if err := other.DoSelectFirstonly(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	items.SetFieldValue("name", "second")
+
+	if err := items.DoUpdate(dbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := version(); got != "2" || items.GetFieldValue("version") != "2" {
+		t.Errorf("got version %s, %s after the update", got, items.GetFieldValue("version"))
+	}
+
+	other.SetFieldValue("name", "concurrent")
+
+	if err := other.DoUpdate(dbc); err != ErrStaleRecord {
+		t.Errorf("got %v for a stale record", err)
+	}
+
+	items.SetFieldValue("version", "7")
+
+	if err := items.DoUpdate(dbc); err == nil {
+		t.Errorf("no error for a set version field")
+	}
+
+	items.ClearFields()
+	items.SetFieldValue("name", "third")
+
+	if _, err := items.DoUpdateWhere(dbc, Where("code", "=", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := version(); got != "3" {
+		t.Errorf("got version %s after DoUpdateWhere", got)
+	}
+
+	items.ClearFields()
+	items.SetFieldValue("code", "a")
+	items.SetFieldValue("name", "fourth")
+
+	if err := items.DoUpsert(dbc, "code"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := version(); got != "4" {
+		t.Errorf("got version %s after updating with DoUpsert", got)
+	}
+
+	items.ClearFields()
+	items.SetFieldValue("code", "b")
+	items.SetFieldValue("name", "new")
+
+	if err := items.DoUpsert(dbc, "code"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := items.GetFieldValue("version"); got != "1" {
+		t.Errorf("got version %s after inserting with DoUpsert", got)
+	}
+}